@@ -54,6 +54,9 @@ func main() {
 			{
 				Name: "vpc-cni",
 			},
+			{
+				Name: "eks-pod-identity-agent",
+			},
 		}
 		clusterSg := &cluster.ClusterSecurityGroup
 		nodeSg := &cluster.NodeSecurityGroup
@@ -64,16 +67,20 @@ func main() {
 		clusterSg.Description = "This is the EKS cluster security group"
 		nodeSg.Description = "This is the EKS nodes security group"
 		cluster.SubnetIds = output.PrivateSubnetsIds
-		cluster.ManagedNodeGroups.Name = "workernode"
-		cluster.ManagedNodeGroups.AmiId = ""
-		cluster.ManagedNodeGroups.CapacityType = "ON_DEMAND"
-		cluster.ManagedNodeGroups.MinSize = 1
-		cluster.ManagedNodeGroups.DesiredSize = 1
-		cluster.ManagedNodeGroups.MaxSize = 2
-		cluster.ManagedNodeGroups.SubnetIds = output.PrivateSubnetsIds
-		cluster.ManagedNodeGroups.LauchTemplate.DiskSize = 200
-		cluster.ManagedNodeGroups.AmiType = "AL2_ARM_64"
-		cluster.ManagedNodeGroups.InstanceTypes = pulumi.StringArray{pulumi.String("t4g.medium")}
+		cluster.ManagedNodeGroups = map[string]eks.NodeGroup{
+			"workernode": {
+				CapacityType:  "ON_DEMAND",
+				MinSize:       1,
+				DesiredSize:   1,
+				MaxSize:       2,
+				SubnetIds:     output.PrivateSubnetsIds,
+				AmiType:       "AL2_ARM_64",
+				InstanceTypes: pulumi.StringArray{pulumi.String("t4g.medium")},
+				LaunchTemplate: eks.LaunchTemplate{
+					DiskSize: 200,
+				},
+			},
+		}
 
 		_, err = cluster.CreateEKS(ctx)
 		if err != nil {
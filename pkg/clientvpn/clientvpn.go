@@ -0,0 +1,253 @@
+package clientvpn
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/acm"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudwatch"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2clientvpn"
+	"github.com/pulumi/pulumi-tls/sdk/v4/go/tls"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func (c *ClientVpn) CreateClientVpn(ctx *pulumi.Context) (*ClientVpnCreateOutput, error) {
+	clientVpnCreateOutput := &ClientVpnCreateOutput{}
+
+	if !c.MutualTls && c.SamlProviderArn == nil {
+		return clientVpnCreateOutput, fmt.Errorf("client vpn %s: enable MutualTls or set a SamlProviderArn", c.Name)
+	}
+
+	// Server certificate, generated with the tls provider or supplied
+	serverCertificateArn := c.ServerCertificateArn
+	if c.Certificates.Generate {
+		certificateArn, err := c.createCertificates(ctx, clientVpnCreateOutput)
+		if err != nil {
+			return clientVpnCreateOutput, err
+		}
+		serverCertificateArn = certificateArn
+	}
+	if serverCertificateArn == nil {
+		return clientVpnCreateOutput, fmt.Errorf("client vpn %s: set ServerCertificateArn or generate the certificates", c.Name)
+	}
+
+	// Authentication, client certificates are checked against the server certificate chain unless a client root chain is given
+	authenticationOptions := ec2clientvpn.EndpointAuthenticationOptionArray{}
+	if c.MutualTls {
+		rootCertificateChainArn := serverCertificateArn
+		if c.ClientRootCertificateChainArn != nil {
+			rootCertificateChainArn = c.ClientRootCertificateChainArn
+		}
+		authenticationOptions = append(authenticationOptions, ec2clientvpn.EndpointAuthenticationOptionArgs{
+			Type:                    pulumi.String("certificate-authentication"),
+			RootCertificateChainArn: rootCertificateChainArn.ToStringOutput(),
+		})
+	}
+	if c.SamlProviderArn != nil {
+		authenticationOptions = append(authenticationOptions, ec2clientvpn.EndpointAuthenticationOptionArgs{
+			Type:            pulumi.String("federated-authentication"),
+			SamlProviderArn: c.SamlProviderArn,
+		})
+	}
+
+	// Connection logging
+	connectionLogOptions := ec2clientvpn.EndpointConnectionLogOptionsArgs{
+		Enabled: pulumi.Bool(c.ConnectionLog.Enabled),
+	}
+	if c.ConnectionLog.Enabled {
+		logGroup, err := cloudwatch.NewLogGroup(ctx, c.Name+"-client-vpn", &cloudwatch.LogGroupArgs{
+			Name:            pulumi.String("/aws/client-vpn/" + c.Name),
+			RetentionInDays: c.ConnectionLog.RetentionInDays,
+			Tags:            c.Tags,
+		})
+		if err != nil {
+			return clientVpnCreateOutput, err
+		}
+		logStream, err := cloudwatch.NewLogStream(ctx, c.Name+"-client-vpn", &cloudwatch.LogStreamArgs{
+			Name:         pulumi.String("connections"),
+			LogGroupName: logGroup.Name,
+		})
+		if err != nil {
+			return clientVpnCreateOutput, err
+		}
+		connectionLogOptions.CloudwatchLogGroup = logGroup.Name
+		connectionLogOptions.CloudwatchLogStream = logStream.Name
+	}
+
+	// Client VPN Security Group
+	securityGroup, err := ec2.NewSecurityGroup(ctx, c.Name+"-client-vpn", &ec2.SecurityGroupArgs{
+		Description: pulumi.String("Client VPN endpoint " + c.Name),
+		VpcId:       c.VpcId,
+		Egress: ec2.SecurityGroupEgressArray{
+			ec2.SecurityGroupEgressArgs{
+				FromPort:    pulumi.Int(0),
+				ToPort:      pulumi.Int(0),
+				Protocol:    pulumi.String("-1"),
+				CidrBlocks:  pulumi.StringArray{pulumi.String("0.0.0.0/0")},
+				Description: pulumi.String("Allow all egress"),
+			},
+		},
+		Tags: mergeTags(pulumi.StringMap{"Name": pulumi.String(c.Name + "-client-vpn")}, c.Tags),
+	})
+	if err != nil {
+		return clientVpnCreateOutput, err
+	}
+	clientVpnCreateOutput.SecurityGroupId = securityGroup.ID()
+
+	endpointArgs := &ec2clientvpn.EndpointArgs{
+		Description:           c.Description,
+		ClientCidrBlock:       c.ClientCidrBlock,
+		ServerCertificateArn:  serverCertificateArn,
+		AuthenticationOptions: authenticationOptions,
+		ConnectionLogOptions:  connectionLogOptions,
+		DnsServers:            c.DnsServers,
+		SplitTunnel:           c.SplitTunnel,
+		SecurityGroupIds:      pulumi.StringArray{securityGroup.ID()},
+		VpcId:                 c.VpcId.ToStringOutput(),
+		Tags:                  mergeTags(pulumi.StringMap{"Name": pulumi.String(c.Name)}, c.Tags),
+	}
+	if c.SessionTimeoutHours != 0 {
+		endpointArgs.SessionTimeoutHours = c.SessionTimeoutHours
+	}
+	endpoint, err := ec2clientvpn.NewEndpoint(ctx, c.Name, endpointArgs)
+	if err != nil {
+		return clientVpnCreateOutput, err
+	}
+	clientVpnCreateOutput.EndpointId = endpoint.ID()
+	clientVpnCreateOutput.DnsName = endpoint.DnsName
+
+	// Network associations
+	for index, subnetId := range c.SubnetIds {
+		_, err = ec2clientvpn.NewNetworkAssociation(ctx, c.Name+strconv.Itoa(index), &ec2clientvpn.NetworkAssociationArgs{
+			ClientVpnEndpointId: endpoint.ID(),
+			SubnetId:            subnetId,
+		})
+		if err != nil {
+			return clientVpnCreateOutput, err
+		}
+	}
+
+	// Authorization rules
+	for index, rule := range c.AuthorizationRules {
+		ruleArgs := &ec2clientvpn.AuthorizationRuleArgs{
+			ClientVpnEndpointId: endpoint.ID(),
+			TargetNetworkCidr:   pulumi.String(rule.TargetNetworkCidr),
+			Description:         pulumi.String(rule.Description),
+		}
+		if rule.AccessGroupId != "" {
+			ruleArgs.AccessGroupId = pulumi.String(rule.AccessGroupId)
+		} else {
+			ruleArgs.AuthorizeAllGroups = pulumi.Bool(true)
+		}
+		_, err = ec2clientvpn.NewAuthorizationRule(ctx, c.Name+strconv.Itoa(index), ruleArgs)
+		if err != nil {
+			return clientVpnCreateOutput, err
+		}
+	}
+
+	return clientVpnCreateOutput, nil
+}
+
+// createCertificates creates a CA, a server certificate imported into ACM and
+// one client certificate per client name, all signed by the same CA. The
+// server certificate also serves as root chain for mutual authentication.
+func (c *ClientVpn) createCertificates(ctx *pulumi.Context, clientVpnCreateOutput *ClientVpnCreateOutput) (pulumi.StringInput, error) {
+	validityPeriodHours := c.Certificates.ValidityPeriodHours
+	if validityPeriodHours == 0 {
+		validityPeriodHours = 8760
+	}
+
+	caKey, err := tls.NewPrivateKey(ctx, c.Name+"-ca", &tls.PrivateKeyArgs{
+		Algorithm: pulumi.String("RSA"),
+		RsaBits:   pulumi.Int(2048),
+	})
+	if err != nil {
+		return nil, err
+	}
+	caCert, err := tls.NewSelfSignedCert(ctx, c.Name+"-ca", &tls.SelfSignedCertArgs{
+		PrivateKeyPem:       caKey.PrivateKeyPem,
+		IsCaCertificate:     pulumi.Bool(true),
+		ValidityPeriodHours: validityPeriodHours,
+		AllowedUses: pulumi.ToStringArray([]string{
+			"cert_signing",
+			"crl_signing",
+		}),
+		Subject: &tls.SelfSignedCertSubjectArgs{
+			CommonName:   pulumi.String(c.Name + " CA"),
+			Organization: pulumi.String(c.Certificates.Organization),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	clientVpnCreateOutput.CaCertPem = caCert.CertPem
+
+	signCertificate := func(name, commonName string, allowedUses []string) (*tls.PrivateKey, *tls.LocallySignedCert, error) {
+		key, err := tls.NewPrivateKey(ctx, name, &tls.PrivateKeyArgs{
+			Algorithm: pulumi.String("RSA"),
+			RsaBits:   pulumi.Int(2048),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		request, err := tls.NewCertRequest(ctx, name, &tls.CertRequestArgs{
+			PrivateKeyPem: key.PrivateKeyPem,
+			DnsNames:      pulumi.StringArray{pulumi.String(commonName)},
+			Subject: &tls.CertRequestSubjectArgs{
+				CommonName:   pulumi.String(commonName),
+				Organization: pulumi.String(c.Certificates.Organization),
+			},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		cert, err := tls.NewLocallySignedCert(ctx, name, &tls.LocallySignedCertArgs{
+			CertRequestPem:      request.CertRequestPem,
+			CaPrivateKeyPem:     caKey.PrivateKeyPem,
+			CaCertPem:           caCert.CertPem,
+			ValidityPeriodHours: validityPeriodHours,
+			AllowedUses:         pulumi.ToStringArray(allowedUses),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, cert, nil
+	}
+
+	serverKey, serverCert, err := signCertificate(c.Name+"-server", c.Certificates.ServerDomain, []string{
+		"key_encipherment",
+		"digital_signature",
+		"server_auth",
+	})
+	if err != nil {
+		return nil, err
+	}
+	serverCertificate, err := acm.NewCertificate(ctx, c.Name+"-server", &acm.CertificateArgs{
+		PrivateKey:       serverKey.PrivateKeyPem,
+		CertificateBody:  serverCert.CertPem,
+		CertificateChain: caCert.CertPem,
+		Tags:             mergeTags(pulumi.StringMap{"Name": pulumi.String(c.Name + "-server")}, c.Tags),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	clientVpnCreateOutput.ClientCertificates = map[string]ClientCertificate{}
+	for _, clientName := range c.Certificates.ClientNames {
+		clientKey, clientCert, err := signCertificate(c.Name+"-client-"+clientName, clientName+"."+c.Certificates.ServerDomain, []string{
+			"key_encipherment",
+			"digital_signature",
+			"client_auth",
+		})
+		if err != nil {
+			return nil, err
+		}
+		clientVpnCreateOutput.ClientCertificates[clientName] = ClientCertificate{
+			CertPem:       clientCert.CertPem,
+			PrivateKeyPem: pulumi.ToSecret(clientKey.PrivateKeyPem).(pulumi.StringOutput),
+		}
+	}
+
+	return serverCertificate.Arn, nil
+}
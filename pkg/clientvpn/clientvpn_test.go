@@ -0,0 +1,134 @@
+package clientvpn
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type mocks struct {
+	sync.Mutex
+	resources map[string]resource.PropertyMap
+}
+
+func newMocks() *mocks {
+	return &mocks{resources: map[string]resource.PropertyMap{}}
+}
+
+func (m *mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	m.Lock()
+	m.resources[args.TypeToken+"::"+args.Name] = args.Inputs
+	m.Unlock()
+	outputs := args.Inputs.Copy()
+	if args.TypeToken == "aws:acm/certificate:Certificate" {
+		outputs["arn"] = resource.NewStringProperty("arn:aws:acm:eu-central-1:123456789012:certificate/" + args.Name)
+	}
+	return args.Name + "_id", outputs, nil
+}
+
+func (m *mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+// inputs returns the inputs of a resource, or nil if it was not registered.
+func (m *mocks) inputs(typeToken, name string) resource.PropertyMap {
+	m.Lock()
+	defer m.Unlock()
+	return m.resources[typeToken+"::"+name]
+}
+
+func testClientVpn() *ClientVpn {
+	return &ClientVpn{
+		Name:            "dev",
+		ClientCidrBlock: pulumi.String("10.100.0.0/22"),
+		SubnetIds:       pulumi.StringArray{pulumi.String("subnet-a"), pulumi.String("subnet-b")},
+		VpcId:           pulumi.String("vpc-1"),
+		AuthorizationRules: []AuthorizationRule{
+			{TargetNetworkCidr: "10.0.0.0/16"},
+			{TargetNetworkCidr: "10.1.0.0/16", AccessGroupId: "platform"},
+		},
+	}
+}
+
+func TestCreateClientVpn(t *testing.T) {
+	m := newMocks()
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		c := testClientVpn()
+		c.MutualTls = true
+		c.Certificates = Certificates{Generate: true, ServerDomain: "vpn.example.com", ClientNames: []string{"alice"}}
+		c.ConnectionLog = ConnectionLog{Enabled: true}
+		_, err := c.CreateClientVpn(ctx)
+		return err
+	}, pulumi.WithMocks("project", "stack", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoint := m.inputs("aws:ec2clientvpn/endpoint:Endpoint", "dev")
+	serverCertificateArn := "arn:aws:acm:eu-central-1:123456789012:certificate/dev-server"
+	if endpoint["serverCertificateArn"].StringValue() != serverCertificateArn {
+		t.Errorf("got server certificate %v", endpoint["serverCertificateArn"])
+	}
+	options := endpoint["authenticationOptions"].ArrayValue()
+	if len(options) != 1 || options[0].ObjectValue()["rootCertificateChainArn"].StringValue() != serverCertificateArn {
+		t.Errorf("got authentication options %v", options)
+	}
+	if endpoint["connectionLogOptions"].ObjectValue()["cloudwatchLogGroup"].StringValue() != "/aws/client-vpn/dev" {
+		t.Errorf("got connection log options %v", endpoint["connectionLogOptions"])
+	}
+	if m.inputs("tls:index/locallySignedCert:LocallySignedCert", "dev-client-alice") == nil {
+		t.Error("no client certificate for alice")
+	}
+	for _, name := range []string{"dev0", "dev1"} {
+		if m.inputs("aws:ec2clientvpn/networkAssociation:NetworkAssociation", name) == nil {
+			t.Errorf("no network association %s", name)
+		}
+	}
+	if !m.inputs("aws:ec2clientvpn/authorizationRule:AuthorizationRule", "dev0")["authorizeAllGroups"].BoolValue() {
+		t.Error("a rule without an access group does not authorize all groups")
+	}
+	if m.inputs("aws:ec2clientvpn/authorizationRule:AuthorizationRule", "dev1")["accessGroupId"].StringValue() != "platform" {
+		t.Error("a rule with an access group is not limited to it")
+	}
+}
+
+func TestCreateClientVpnSaml(t *testing.T) {
+	m := newMocks()
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		c := testClientVpn()
+		c.SamlProviderArn = pulumi.String("arn:aws:iam::123456789012:saml-provider/sso")
+		c.ServerCertificateArn = pulumi.String("arn:aws:acm:eu-central-1:123456789012:certificate/existing")
+		_, err := c.CreateClientVpn(ctx)
+		return err
+	}, pulumi.WithMocks("project", "stack", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := m.inputs("aws:ec2clientvpn/endpoint:Endpoint", "dev")["authenticationOptions"].ArrayValue()
+	if len(options) != 1 || options[0].ObjectValue()["type"].StringValue() != "federated-authentication" {
+		t.Errorf("got authentication options %v", options)
+	}
+	if m.inputs("tls:index/privateKey:PrivateKey", "dev-ca") != nil {
+		t.Error("generated certificates that were not asked for")
+	}
+}
+
+func TestCreateClientVpnErrors(t *testing.T) {
+	tests := map[string]func(c *ClientVpn){
+		"no authentication":     func(c *ClientVpn) { c.ServerCertificateArn = pulumi.String("arn") },
+		"no server certificate": func(c *ClientVpn) { c.MutualTls = true },
+	}
+	for name, configure := range tests {
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			c := testClientVpn()
+			configure(c)
+			_, err := c.CreateClientVpn(ctx)
+			return err
+		}, pulumi.WithMocks("project", "stack", newMocks()))
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package clientvpn
+
+import (
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type ClientVpn struct {
+	AuthorizationRules            []AuthorizationRule
+	Certificates                  Certificates
+	ClientCidrBlock               pulumi.String
+	ClientRootCertificateChainArn pulumi.StringInput
+	ConnectionLog                 ConnectionLog
+	Description                   pulumi.String
+	DnsServers                    pulumi.StringArray
+	MutualTls                     bool
+	Name                          string
+	SamlProviderArn               pulumi.StringPtrInput
+	ServerCertificateArn          pulumi.StringInput
+	SessionTimeoutHours           pulumi.Int
+	SplitTunnel                   pulumi.Bool
+	SubnetIds                     pulumi.StringArray
+	Tags                          pulumi.StringMap
+	VpcId                         pulumi.StringInput
+}
+
+type AuthorizationRule struct {
+	AccessGroupId     string
+	Description       string
+	TargetNetworkCidr string
+}
+
+type Certificates struct {
+	ClientNames         []string
+	Generate            bool
+	Organization        string
+	ServerDomain        string
+	ValidityPeriodHours pulumi.Int
+}
+
+type ConnectionLog struct {
+	Enabled         bool
+	RetentionInDays pulumi.Int
+}
+
+type ClientCertificate struct {
+	CertPem       pulumi.StringOutput
+	PrivateKeyPem pulumi.StringOutput
+}
+
+type ClientVpnCreateOutput struct {
+	EndpointId         pulumi.IDOutput
+	DnsName            pulumi.StringOutput
+	SecurityGroupId    pulumi.IDOutput
+	CaCertPem          pulumi.StringOutput
+	ClientCertificates map[string]ClientCertificate
+}
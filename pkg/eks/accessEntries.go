@@ -0,0 +1,121 @@
+package eks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// accessPolicyNames are the short names of the EKS cluster access policies.
+var accessPolicyNames = map[string]string{
+	"cluster-admin": "AmazonEKSClusterAdminPolicy",
+	"admin":         "AmazonEKSAdminPolicy",
+	"edit":          "AmazonEKSEditPolicy",
+	"view":          "AmazonEKSViewPolicy",
+}
+
+// accessPolicyArn returns the ARN of an access policy given by its short name
+// or by its ARN.
+func accessPolicyArn(partition, policy string) (string, error) {
+	if strings.HasPrefix(policy, "arn:") {
+		return policy, nil
+	}
+	name, ok := accessPolicyNames[policy]
+	if !ok {
+		return "", fmt.Errorf("unknown access policy %q", policy)
+	}
+	return fmt.Sprintf("arn:%s:eks::aws:cluster-access-policy/%s", partition, name), nil
+}
+
+// authenticationMode returns the authentication mode of the cluster, or "" for
+// the EKS default. Access entries need the API authentication mode, so it is
+// turned on alongside aws-auth when entries are configured without a mode.
+func (e *Eks) authenticationMode() pulumi.String {
+	mode := e.AccessConfig.AuthenticationMode
+	if mode == "" && len(e.AccessConfig.Entries) > 0 {
+		mode = "API_AND_CONFIG_MAP"
+	}
+	return mode
+}
+
+// hasAccessEntries reports whether the cluster authenticates through access
+// entries.
+func (e *Eks) hasAccessEntries() bool {
+	mode := e.authenticationMode()
+	return mode == "API" || mode == "API_AND_CONFIG_MAP"
+}
+
+// clusterAccessConfig returns the access config of the cluster, or nil to keep
+// the EKS defaults.
+func (e *Eks) clusterAccessConfig() (*eks.ClusterAccessConfigArgs, error) {
+	mode := e.authenticationMode()
+	if mode == "CONFIG_MAP" && len(e.AccessConfig.Entries) > 0 {
+		return nil, fmt.Errorf("cluster %s: access entries need the API or API_AND_CONFIG_MAP authentication mode", e.Name)
+	}
+	if mode == "" && !e.AccessConfig.SkipCreatorAdminBootstrap {
+		return nil, nil
+	}
+
+	accessConfig := &eks.ClusterAccessConfigArgs{}
+	if mode != "" {
+		accessConfig.AuthenticationMode = mode
+	}
+	if e.AccessConfig.SkipCreatorAdminBootstrap {
+		accessConfig.BootstrapClusterCreatorAdminPermissions = pulumi.Bool(false)
+	}
+	return accessConfig, nil
+}
+
+// CreateAccessEntries creates an access entry per entry of AccessConfig.Entries
+// and associates its access policies, scoped to the cluster or to namespaces.
+func (e *Eks) CreateAccessEntries(ctx *pulumi.Context, cluster *eks.Cluster, partition string) (map[string]*eks.AccessEntry, error) {
+	accessEntries := map[string]*eks.AccessEntry{}
+
+	for _, key := range sortedKeys(e.AccessConfig.Entries) {
+		entry := e.AccessConfig.Entries[key]
+		entryArgs := &eks.AccessEntryArgs{
+			ClusterName:      cluster.Name,
+			PrincipalArn:     entry.PrincipalArn,
+			KubernetesGroups: entry.KubernetesGroups,
+			Tags:             mergeTags(e.Tags, entry.Tags),
+		}
+		if entry.Type != "" {
+			entryArgs.Type = entry.Type
+		}
+		if entry.Username != "" {
+			entryArgs.UserName = entry.Username
+		}
+		accessEntry, err := eks.NewAccessEntry(ctx, e.Name+"-"+key, entryArgs)
+		if err != nil {
+			return accessEntries, err
+		}
+		accessEntries[key] = accessEntry
+
+		for index, policy := range entry.Policies {
+			policyArn, err := accessPolicyArn(partition, policy.Policy)
+			if err != nil {
+				return accessEntries, fmt.Errorf("access entry %s: %w", key, err)
+			}
+			accessScope := &eks.AccessPolicyAssociationAccessScopeArgs{
+				Type: pulumi.String("cluster"),
+			}
+			if len(policy.Namespaces) > 0 {
+				accessScope.Type = pulumi.String("namespace")
+				accessScope.Namespaces = policy.Namespaces
+			}
+			_, err = eks.NewAccessPolicyAssociation(ctx, e.Name+"-"+key+"-"+strconv.Itoa(index), &eks.AccessPolicyAssociationArgs{
+				ClusterName:  cluster.Name,
+				PrincipalArn: accessEntry.PrincipalArn,
+				PolicyArn:    pulumi.String(policyArn),
+				AccessScope:  accessScope,
+			})
+			if err != nil {
+				return accessEntries, err
+			}
+		}
+	}
+	return accessEntries, nil
+}
@@ -1,11 +1,11 @@
 package eks
 
 import (
-	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/eks"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
-func (e *Eks) CreateAddon(ctx *pulumi.Context, addon Addon, cluster *eks.Cluster, nodegroup *eks.NodeGroup) error {
+func (e *Eks) CreateAddon(ctx *pulumi.Context, addon Addon, cluster *eks.Cluster, nodeGroups []pulumi.Resource) error {
 	_addon := &eks.AddonArgs{}
 	_addon.Tags = e.Tags
 	_addon.ClusterName = cluster.Name
@@ -24,9 +24,9 @@ func (e *Eks) CreateAddon(ctx *pulumi.Context, addon Addon, cluster *eks.Cluster
 		_addon.ServiceAccountRoleArn = addon.ServiceAccountRoleArn
 	}
 
-	_, err := eks.NewAddon(ctx, addon.Name, _addon, pulumi.DependsOn([]pulumi.Resource{
-		cluster, nodegroup,
-	}))
+	_, err := eks.NewAddon(ctx, addon.Name, _addon, pulumi.DependsOn(append([]pulumi.Resource{
+		cluster,
+	}, nodeGroups...)))
 	if err != nil {
 		return err
 	}
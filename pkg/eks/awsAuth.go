@@ -0,0 +1,208 @@
+package eks
+
+import (
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"gopkg.in/yaml.v3"
+)
+
+// awsAuthOwnedAnnotation records the mappings written by the last update, so
+// the ones removed from AwsAuth since are dropped rather than kept as entries
+// of someone else.
+const awsAuthOwnedAnnotation = "pulumi-aws-go/aws-auth-mappings"
+
+type awsAuthMappings struct {
+	MapAccounts []string      `yaml:"mapAccounts"`
+	MapRoles    []AwsAuthRole `yaml:"mapRoles"`
+	MapUsers    []AwsAuthUser `yaml:"mapUsers"`
+}
+
+// createAwsAuth writes the role and user mappings into the kube-system/aws-auth
+// ConfigMap with a server-side apply patch through a Kubernetes provider for
+// the cluster. EKS creates the ConfigMap for managed node groups and Fargate
+// profiles, in which case it is read first so the entries written by others
+// are kept. Otherwise the patch creates it.
+func (e *Eks) createAwsAuth(ctx *pulumi.Context, cluster *eks.Cluster, nodeRoleArns, fargateRoleArns []pulumi.StringOutput, dependsOn []pulumi.Resource) (pulumi.StringMapOutput, error) {
+	provider, err := e.createKubernetesProvider(ctx, cluster)
+	if err != nil {
+		return pulumi.StringMapOutput{}, err
+	}
+
+	inputs := []interface{}{pulumi.StringMap{}, pulumi.StringMap{}}
+	if len(e.ManagedNodeGroups) > 0 || len(e.FargateProfiles) > 0 {
+		existing, err := corev1.GetConfigMap(ctx, e.Name+"-aws-auth-existing", pulumi.ID("kube-system/aws-auth"), nil,
+			pulumi.Provider(provider), pulumi.DependsOn(append([]pulumi.Resource{cluster}, dependsOn...)))
+		if err != nil {
+			return pulumi.StringMapOutput{}, err
+		}
+		inputs = []interface{}{existing.Data, existing.Metadata.Annotations()}
+	}
+	for _, roleArn := range nodeRoleArns {
+		inputs = append(inputs, roleArn)
+	}
+	for _, roleArn := range fargateRoleArns {
+		inputs = append(inputs, roleArn)
+	}
+
+	rendered := pulumi.All(inputs...).ApplyT(func(args []interface{}) (map[string]map[string]string, error) {
+		existing, _ := args[0].(map[string]string)
+		annotations, _ := args[1].(map[string]string)
+		roleArns := []string{}
+		for _, roleArn := range args[2:] {
+			roleArns = append(roleArns, roleArn.(string))
+		}
+		data, owned, err := e.renderAwsAuth(existing, annotations[awsAuthOwnedAnnotation], roleArns[:len(nodeRoleArns)], roleArns[len(nodeRoleArns):])
+		if err != nil {
+			return nil, err
+		}
+		return map[string]map[string]string{
+			"data":        data,
+			"annotations": {awsAuthOwnedAnnotation: owned, "pulumi.com/patchForce": "true"},
+		}, nil
+	}).(pulumi.StringMapMapOutput)
+	data := rendered.MapIndex(pulumi.String("data"))
+
+	// The patch is kept on delete, so nodes keep joining when AwsAuth is
+	// turned off.
+	_, err = corev1.NewConfigMapPatch(ctx, e.Name+"-aws-auth", &corev1.ConfigMapPatchArgs{
+		Metadata: &metav1.ObjectMetaPatchArgs{
+			Name:        pulumi.String("aws-auth"),
+			Namespace:   pulumi.String("kube-system"),
+			Annotations: rendered.MapIndex(pulumi.String("annotations")),
+		},
+		Data: data,
+	}, pulumi.Provider(provider), pulumi.DependsOn(append([]pulumi.Resource{cluster}, dependsOn...)), pulumi.RetainOnDelete(true))
+	if err != nil {
+		return pulumi.StringMapOutput{}, err
+	}
+	return data, nil
+}
+
+// renderAwsAuth renders the data of the kube-system/aws-auth ConfigMap from its
+// existing data and the mappings the last update owned, as recorded in
+// awsAuthOwnedAnnotation. Existing mappings not owned by the module come first,
+// then the node roles and Fargate pod execution roles, then the mappings of
+// AwsAuth. A role or user mapped twice keeps the last mapping. It returns the
+// data and the mappings it owns.
+func (e *Eks) renderAwsAuth(existing map[string]string, owned string, nodeRoleArns, fargateRoleArns []string) (map[string]string, string, error) {
+	current := awsAuthMappings{}
+	for key, value := range map[string]interface{}{
+		"mapAccounts": &current.MapAccounts,
+		"mapRoles":    &current.MapRoles,
+		"mapUsers":    &current.MapUsers,
+	} {
+		if err := yaml.Unmarshal([]byte(existing[key]), value); err != nil {
+			return nil, "", err
+		}
+	}
+	previous := awsAuthMappings{}
+	if err := yaml.Unmarshal([]byte(owned), &previous); err != nil {
+		return nil, "", err
+	}
+
+	mappings := awsAuthMappings{}
+	for _, roleArn := range nodeRoleArns {
+		mappings.MapRoles = append(mappings.MapRoles, AwsAuthRole{
+			RoleArn:  roleArn,
+			Username: "system:node:{{EC2PrivateDNSName}}",
+			Groups:   []string{"system:bootstrappers", "system:nodes"},
+		})
+	}
+	for _, roleArn := range fargateRoleArns {
+		mappings.MapRoles = append(mappings.MapRoles, AwsAuthRole{
+			RoleArn:  roleArn,
+			Username: "system:node:{{SessionName}}",
+			Groups:   []string{"system:bootstrappers", "system:nodes", "system:node-proxier"},
+		})
+	}
+	mappings.MapRoles = append(mappings.MapRoles, e.AwsAuth.MapRoles...)
+	mappings.MapUsers = append([]AwsAuthUser{}, e.AwsAuth.MapUsers...)
+	mappings.MapAccounts = append([]string{}, e.AwsAuth.MapAccounts...)
+
+	mapRoles := []AwsAuthRole{}
+	for _, role := range current.MapRoles {
+		if !containsRole(previous.MapRoles, role.RoleArn) {
+			mapRoles = append(mapRoles, role)
+		}
+	}
+	mapRoles = append(mapRoles, mappings.MapRoles...)
+	roles := []AwsAuthRole{}
+	for index, role := range mapRoles {
+		if !containsRole(mapRoles[index+1:], role.RoleArn) {
+			roles = append(roles, role)
+		}
+	}
+
+	mapUsers := []AwsAuthUser{}
+	for _, user := range current.MapUsers {
+		if !containsUser(previous.MapUsers, user.UserArn) {
+			mapUsers = append(mapUsers, user)
+		}
+	}
+	mapUsers = append(mapUsers, mappings.MapUsers...)
+	users := []AwsAuthUser{}
+	for index, user := range mapUsers {
+		if !containsUser(mapUsers[index+1:], user.UserArn) {
+			users = append(users, user)
+		}
+	}
+
+	accounts := []string{}
+	for _, account := range current.MapAccounts {
+		if !containsAccount(previous.MapAccounts, account) && !containsAccount(accounts, account) {
+			accounts = append(accounts, account)
+		}
+	}
+	for _, account := range mappings.MapAccounts {
+		if !containsAccount(accounts, account) {
+			accounts = append(accounts, account)
+		}
+	}
+
+	data := map[string]string{}
+	for key, value := range map[string]interface{}{
+		"mapAccounts": accounts,
+		"mapRoles":    roles,
+		"mapUsers":    users,
+	} {
+		rendered, err := yaml.Marshal(value)
+		if err != nil {
+			return nil, "", err
+		}
+		data[key] = string(rendered)
+	}
+	rendered, err := yaml.Marshal(mappings)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, string(rendered), nil
+}
+
+func containsRole(roles []AwsAuthRole, roleArn string) bool {
+	for _, role := range roles {
+		if role.RoleArn == roleArn {
+			return true
+		}
+	}
+	return false
+}
+
+func containsUser(users []AwsAuthUser, userArn string) bool {
+	for _, user := range users {
+		if user.UserArn == userArn {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAccount(accounts []string, account string) bool {
+	for _, value := range accounts {
+		if value == account {
+			return true
+		}
+	}
+	return false
+}
@@ -2,20 +2,25 @@ package eks
 
 import (
 	"strconv"
+	"strings"
 
-	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws"
-	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/cloudwatch"
-	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudwatch"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
 	"github.com/pulumi/pulumi-tls/sdk/v4/go/tls"
 
-	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/eks"
-	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
 func (e *Eks) CreateEKS(ctx *pulumi.Context) (*EksCreateOutPut, error) {
 	eksCreateOutput := &EksCreateOutPut{}
 
+	if err := e.checkIrsaEnabled(ctx); err != nil {
+		return eksCreateOutput, err
+	}
+
 	//Get partition
 	partition, err := aws.GetPartition(ctx, nil, nil)
 	if err != nil {
@@ -47,10 +52,14 @@ func (e *Eks) CreateEKS(ctx *pulumi.Context) (*EksCreateOutPut, error) {
 		return eksCreateOutput, err
 	}
 
-	nodeSg, err := ec2.NewSecurityGroup(ctx, e.Name+"-node", &ec2.SecurityGroupArgs{
+	nodeSgArgs := &ec2.SecurityGroupArgs{
 		Description: e.NodeSecurityGroup.Description,
 		VpcId:       e.NodeSecurityGroup.VpcId,
-	})
+	}
+	if e.Karpenter.Enabled {
+		nodeSgArgs.Tags = pulumi.StringMap{"karpenter.sh/discovery": pulumi.String(e.Name)}
+	}
+	nodeSg, err := ec2.NewSecurityGroup(ctx, e.Name+"-node", nodeSgArgs)
 	if err != nil {
 		return eksCreateOutput, err
 	}
@@ -78,6 +87,7 @@ func (e *Eks) CreateEKS(ctx *pulumi.Context) (*EksCreateOutPut, error) {
 			// },
 		}
 		clusterSecurityGroupRules = append(clusterSecurityGroupRules, e.ClusterSecurityGroup.AdditionalRules...)
+		clusterSecurityGroupRules = append(clusterSecurityGroupRules, prefixListRules(e.ClusterSecurityGroup.PrefixListRules)...)
 		for index, rule := range clusterSecurityGroupRules {
 			securityGroupRuleArgs := createSecurityGroupRule(rule)
 			securityGroupRuleArgs.SecurityGroupId = clusterSg.ID()
@@ -162,8 +172,13 @@ func (e *Eks) CreateEKS(ctx *pulumi.Context) (*EksCreateOutPut, error) {
 	}
 
 	// Create EKS cluster
+	accessConfig, err := e.clusterAccessConfig()
+	if err != nil {
+		return eksCreateOutput, err
+	}
 	cluster, err := eks.NewCluster(ctx, e.Name, &eks.ClusterArgs{
-		RoleArn: eksRole.Arn,
+		AccessConfig: accessConfig,
+		RoleArn:      eksRole.Arn,
 		VpcConfig: &eks.ClusterVpcConfigArgs{
 			EndpointPrivateAccess: e.ClusterEndpointPrivateAccess,
 			EndpointPublicAccess:  e.ClusterEndpointPublicAccess,
@@ -208,6 +223,16 @@ func (e *Eks) CreateEKS(ctx *pulumi.Context) (*EksCreateOutPut, error) {
 		if err != nil {
 			return eksCreateOutput, err
 		}
+		if e.Karpenter.Enabled {
+			_, err = ec2.NewTag(ctx, e.Name+"-karpenter-subnet-"+strconv.Itoa(index), &ec2.TagArgs{
+				ResourceId: subnet,
+				Key:        pulumi.String("karpenter.sh/discovery"),
+				Value:      pulumi.String(e.Name),
+			})
+			if err != nil {
+				return eksCreateOutput, err
+			}
+		}
 	}
 
 	// Create Cloudwatch Log Group
@@ -227,36 +252,133 @@ func (e *Eks) CreateEKS(ctx *pulumi.Context) (*EksCreateOutPut, error) {
 	//# IRSA
 	//# Note - this is different from EKS identity provider
 	//################################################################################
-	clusterCert := tls.GetCertificateOutput(ctx, tls.GetCertificateOutputArgs{
-		Url: cluster.Identities.Index(pulumi.Int(0)).Oidcs().Index(pulumi.Int(0)).Issuer(),
-	})
-	_, err = iam.NewOpenIdConnectProvider(ctx, e.Name, &iam.OpenIdConnectProviderArgs{
-		ClientIdLists: pulumi.StringArray{
-			pulumi.String("sts.amazonaws.com"),
-		},
-		ThumbprintLists: pulumi.StringArray{
-			clusterCert.Certificates().Index(pulumi.Int(0)).Sha1Fingerprint(),
-		},
-		Url: cluster.Identities.Index(pulumi.Int(0)).Oidcs().Index(pulumi.Int(0)).Issuer().Elem().ToStringOutput(),
-	})
+	if !e.Irsa.Disabled {
+		issuer := cluster.Identities.Index(pulumi.Int(0)).Oidcs().Index(pulumi.Int(0)).Issuer().Elem().ToStringOutput()
+		if e.Irsa.ExistingOidcProviderArn != "" {
+			eksCreateOutput.OidcProviderArn = e.Irsa.ExistingOidcProviderArn.ToStringOutput()
+		} else {
+			clusterCert := tls.GetCertificateOutput(ctx, tls.GetCertificateOutputArgs{
+				Url: issuer,
+			})
+			oidcProvider, err := iam.NewOpenIdConnectProvider(ctx, e.Name, &iam.OpenIdConnectProviderArgs{
+				ClientIdLists: append(pulumi.StringArray{
+					pulumi.String("sts.amazonaws.com"),
+				}, e.Irsa.OpendIdConnectAudiences...),
+				ThumbprintLists: append(pulumi.StringArray{
+					clusterCert.Certificates().Index(pulumi.Int(0)).Sha1Fingerprint(),
+				}, e.Irsa.CustomOidcThumbprints...),
+				Url:  issuer,
+				Tags: e.Tags,
+			})
+			if err != nil {
+				return eksCreateOutput, err
+			}
+			eksCreateOutput.OidcProviderArn = oidcProvider.Arn
+		}
+		eksCreateOutput.OidcProviderUrl = issuer.ApplyT(func(url string) string {
+			return strings.TrimPrefix(url, "https://")
+		}).(pulumi.StringOutput)
+		ctx.Export(e.Name+"-oidc-provider-arn", eksCreateOutput.OidcProviderArn)
+		ctx.Export(e.Name+"-oidc-issuer-url", issuer)
+	}
+
+	// Create node role and NodeGroups
+	nodeRoleArn, nodeRoleName, err := e.createNodeRole(ctx)
 	if err != nil {
 		return eksCreateOutput, err
 	}
+	eksCreateOutput.NodeRoleArn = nodeRoleArn
+	eksCreateOutput.NodeRoleName = nodeRoleName
+	eksCreateOutput.NodeSecurityGroupId = nodeSg.ID()
 
-	// Create NodeGroup
-	nodeGroupOutput, err := e.CreateEksNodeGroups(ctx, nodeSg.ID(), clusterSg.ID(), cluster.Name)
+	nodeGroupsOutput, err := e.CreateEksNodeGroups(ctx, nodeSg.ID(), clusterSg.ID(), cluster.Name, nodeRoleArn)
+	if err != nil {
+		return eksCreateOutput, err
+	}
+	eksCreateOutput.NodeGroups = nodeGroupsOutput
+	nodeGroups := []pulumi.Resource{}
+	for _, key := range sortedKeys(e.ManagedNodeGroups) {
+		nodeGroups = append(nodeGroups, nodeGroupsOutput[key].NodeGroup)
+	}
+
+	// Create self-managed NodeGroups
+	selfManagedNodeGroupsOutput, err := e.CreateSelfManagedNodeGroups(ctx, cluster, nodeSg.ID(), nodeRoleName)
+	if err != nil {
+		return eksCreateOutput, err
+	}
+	eksCreateOutput.SelfManagedNodeGroups = selfManagedNodeGroupsOutput
+	for _, key := range sortedKeys(e.SelfManagedNodeGroups) {
+		nodeGroups = append(nodeGroups, selfManagedNodeGroupsOutput[key].AutoscalingGroup)
+	}
+	// Create Fargate profiles
+	fargateOutput, err := e.CreateFargateProfiles(ctx, cluster)
 	if err != nil {
 		return eksCreateOutput, err
 	}
-	eksCreateOutput.NodeGroupOutput = nodeGroupOutput
+	eksCreateOutput.Fargate = fargateOutput
+	for _, key := range sortedKeys(e.FargateProfiles) {
+		nodeGroups = append(nodeGroups, fargateOutput.Profiles[key])
+	}
+
+	// Create Karpenter prerequisites
+	if e.Karpenter.Enabled {
+		if err := e.validateKarpenter(); err != nil {
+			return eksCreateOutput, err
+		}
+		karpenterOutput, err := e.CreateKarpenter(ctx, cluster, eksCreateOutput.OidcProviderArn, eksCreateOutput.OidcProviderUrl)
+		if err != nil {
+			return eksCreateOutput, err
+		}
+		eksCreateOutput.Karpenter = karpenterOutput
+	}
+
+	// Patch the aws-auth ConfigMap
+	if e.AwsAuth.Enabled {
+		nodeRoleArns := []pulumi.StringOutput{}
+		if e.createsNodeRole() {
+			nodeRoleArns = append(nodeRoleArns, nodeRoleArn)
+		}
+		if eksCreateOutput.Karpenter != nil && eksCreateOutput.Karpenter.NodeAccessEntry == nil {
+			nodeRoleArns = append(nodeRoleArns, eksCreateOutput.Karpenter.NodeRoleArn)
+		}
+		fargateRoleArns := []pulumi.StringOutput{}
+		if len(e.FargateProfiles) > 0 {
+			fargateRoleArns = append(fargateRoleArns, fargateOutput.PodExecutionRoleArn)
+		}
+		eksCreateOutput.AwsAuthData, err = e.createAwsAuth(ctx, cluster, nodeRoleArns, fargateRoleArns, nodeGroups)
+		if err != nil {
+			return eksCreateOutput, err
+		}
+		ctx.Export(e.Name+"-aws-auth", eksCreateOutput.AwsAuthData)
+	}
+
 	// create Addons
 	for _, addon := range e.ClusterAddons {
-		err := e.CreateAddon(ctx, addon, cluster, nodeGroupOutput.NodeGroup)
+		err := e.CreateAddon(ctx, addon, cluster, nodeGroups)
 		if err != nil {
 			return eksCreateOutput, err
 		}
 	}
 
+	// Grant cluster access through access entries
+	accessEntries, err := e.CreateAccessEntries(ctx, cluster, partition.Partition)
+	if err != nil {
+		return eksCreateOutput, err
+	}
+	eksCreateOutput.AccessEntries = accessEntries
+
+	// Associate the OIDC identity provider
+	identityProvider, err := e.CreateIdentityProvider(ctx, cluster)
+	if err != nil {
+		return eksCreateOutput, err
+	}
+	eksCreateOutput.IdentityProvider = identityProvider
+	eksCreateOutput.IdentityProviders = map[string]*eks.IdentityProviderConfig{}
+	if identityProvider != nil {
+		provider, _ := e.identityProvider()
+		eksCreateOutput.IdentityProviders[provider.Name] = identityProvider
+	}
+
 	return eksCreateOutput, nil
 }
 
@@ -0,0 +1,732 @@
+package eks
+
+import (
+	"encoding/base64"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type mocks struct {
+	sync.Mutex
+	resources map[string]resource.PropertyMap
+}
+
+func newMocks() *mocks {
+	return &mocks{resources: map[string]resource.PropertyMap{}}
+}
+
+func (m *mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	m.Lock()
+	m.resources[args.TypeToken+"::"+args.Name] = args.Inputs
+	m.Unlock()
+	outputs := args.Inputs.Copy()
+	switch args.TypeToken {
+	case "aws:eks/cluster:Cluster":
+		outputs["name"] = resource.NewStringProperty(args.Name)
+		outputs["arn"] = resource.NewStringProperty("arn:aws:eks:eu-central-1:123456789012:cluster/" + args.Name)
+		outputs["version"] = resource.NewStringProperty("1.33")
+		outputs["endpoint"] = resource.NewStringProperty("https://" + args.Name + ".eks.amazonaws.com")
+		outputs["certificateAuthority"] = resource.NewObjectProperty(resource.NewPropertyMapFromMap(map[string]interface{}{"data": "Y2VydA=="}))
+		outputs["kubernetesNetworkConfig"] = resource.NewObjectProperty(resource.NewPropertyMapFromMap(map[string]interface{}{"serviceIpv4Cidr": "172.20.0.0/16"}))
+	case "aws:sqs/queue:Queue":
+		outputs["arn"] = resource.NewStringProperty("arn:aws:sqs:eu-central-1:123456789012:" + args.Name)
+		outputs["url"] = resource.NewStringProperty("https://sqs.eu-central-1.amazonaws.com/123456789012/" + args.Name)
+	case "kubernetes:core/v1:ConfigMap":
+		// The aws-auth ConfigMap EKS created, holding a mapping of someone
+		// else and one written by the last update.
+		outputs = resource.NewPropertyMapFromMap(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      "aws-auth",
+				"namespace": "kube-system",
+				"annotations": map[string]interface{}{
+					awsAuthOwnedAnnotation: "mapRoles:\n  - rolearn: arn:aws:iam::123456789012:role/removed\n",
+				},
+			},
+			"data": map[string]interface{}{
+				"mapRoles": "- rolearn: arn:aws:iam::123456789012:role/admin\n  username: admin\n  groups: [system:masters]\n- rolearn: arn:aws:iam::123456789012:role/removed\n  username: removed\n",
+			},
+		})
+	case "aws:iam/role:Role":
+		outputs["arn"] = resource.NewStringProperty("arn:aws:iam::123456789012:role/" + args.Name)
+		outputs["name"] = resource.NewStringProperty(args.Name)
+	}
+	return args.Name + "_id", outputs, nil
+}
+
+func (m *mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	switch args.Token {
+	case "aws:index/getPartition:getPartition":
+		return resource.NewPropertyMapFromMap(map[string]interface{}{"partition": "aws", "id": "aws"}), nil
+	case "aws:index/getRegion:getRegion":
+		return resource.NewPropertyMapFromMap(map[string]interface{}{"name": "eu-central-1", "id": "eu-central-1"}), nil
+	case "aws:ssm/getParameter:getParameter":
+		m.Lock()
+		m.resources[args.Token+"::"+args.Args["name"].StringValue()] = args.Args
+		m.Unlock()
+		return resource.NewPropertyMapFromMap(map[string]interface{}{"value": "ami-0123456789", "name": args.Args["name"].StringValue()}), nil
+	}
+	return args.Args, nil
+}
+
+// has reports whether a resource of the given type and name was registered.
+func (m *mocks) has(typeToken, name string) bool {
+	m.Lock()
+	defer m.Unlock()
+	_, ok := m.resources[typeToken+"::"+name]
+	return ok
+}
+
+func (m *mocks) inputs(typeToken, name string) resource.PropertyMap {
+	m.Lock()
+	defer m.Unlock()
+	return m.resources[typeToken+"::"+name]
+}
+
+func testCluster(ctx *pulumi.Context) (*eks.Cluster, error) {
+	return eks.NewCluster(ctx, "test", &eks.ClusterArgs{
+		RoleArn: pulumi.String("arn:aws:iam::123456789012:role/test"),
+		VpcConfig: &eks.ClusterVpcConfigArgs{
+			SubnetIds: pulumi.StringArray{pulumi.String("subnet-1")},
+		},
+	})
+}
+
+func TestAccessPolicyArn(t *testing.T) {
+	tests := []struct {
+		policy string
+		arn    string
+	}{
+		{"cluster-admin", "arn:aws:eks::aws:cluster-access-policy/AmazonEKSClusterAdminPolicy"},
+		{"admin", "arn:aws:eks::aws:cluster-access-policy/AmazonEKSAdminPolicy"},
+		{"view", "arn:aws:eks::aws:cluster-access-policy/AmazonEKSViewPolicy"},
+		{"arn:aws:eks::aws:cluster-access-policy/Custom", "arn:aws:eks::aws:cluster-access-policy/Custom"},
+	}
+	for _, tt := range tests {
+		arn, err := accessPolicyArn("aws", tt.policy)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.policy, err)
+		}
+		if arn != tt.arn {
+			t.Errorf("%s: got %s, want %s", tt.policy, arn, tt.arn)
+		}
+	}
+	if _, err := accessPolicyArn("aws", "owner"); err == nil {
+		t.Error("an unknown access policy should fail")
+	}
+}
+
+func TestClusterAccessConfig(t *testing.T) {
+	entries := map[string]AccessEntry{"ci": {PrincipalArn: pulumi.String("arn:aws:iam::123456789012:role/ci")}}
+
+	e := &Eks{Name: "test"}
+	if config, err := e.clusterAccessConfig(); err != nil || config != nil {
+		t.Errorf("no access config: got %v, %v", config, err)
+	}
+
+	e.AccessConfig = AccessConfig{Entries: entries}
+	config, err := e.clusterAccessConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.AuthenticationMode != pulumi.String("API_AND_CONFIG_MAP") || config.BootstrapClusterCreatorAdminPermissions != nil {
+		t.Errorf("entries: got mode %v and bootstrap %v", config.AuthenticationMode, config.BootstrapClusterCreatorAdminPermissions)
+	}
+
+	e.AccessConfig = AccessConfig{AuthenticationMode: "API", SkipCreatorAdminBootstrap: true}
+	config, err = e.clusterAccessConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.BootstrapClusterCreatorAdminPermissions != pulumi.Bool(false) {
+		t.Errorf("skip bootstrap: got %v", config.BootstrapClusterCreatorAdminPermissions)
+	}
+
+	e.AccessConfig = AccessConfig{AuthenticationMode: "CONFIG_MAP", Entries: entries}
+	if _, err := e.clusterAccessConfig(); err == nil {
+		t.Error("access entries with the CONFIG_MAP mode should fail")
+	}
+}
+
+func TestCreateAccessEntries(t *testing.T) {
+	m := newMocks()
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cluster, err := testCluster(ctx)
+		if err != nil {
+			return err
+		}
+		e := &Eks{Name: "test", AccessConfig: AccessConfig{Entries: map[string]AccessEntry{
+			"ci": {
+				PrincipalArn: pulumi.String("arn:aws:iam::123456789012:role/ci"),
+				Policies: []AccessPolicy{
+					{Policy: "cluster-admin"},
+					{Policy: "view", Namespaces: pulumi.StringArray{pulumi.String("apps")}},
+				},
+			},
+		}}}
+		_, err = e.CreateAccessEntries(ctx, cluster, "aws")
+		return err
+	}, pulumi.WithMocks("project", "stack", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.has("aws:eks/accessEntry:AccessEntry", "test-ci") {
+		t.Error("access entry test-ci was not created")
+	}
+	clusterScope := m.inputs("aws:eks/accessPolicyAssociation:AccessPolicyAssociation", "test-ci-0")
+	if clusterScope["accessScope"].ObjectValue()["type"].StringValue() != "cluster" {
+		t.Errorf("test-ci-0: got scope %v", clusterScope["accessScope"])
+	}
+	namespaceScope := m.inputs("aws:eks/accessPolicyAssociation:AccessPolicyAssociation", "test-ci-1")
+	if namespaceScope["accessScope"].ObjectValue()["type"].StringValue() != "namespace" {
+		t.Errorf("test-ci-1: got scope %v", namespaceScope["accessScope"])
+	}
+	if namespaceScope["policyArn"].StringValue() != "arn:aws:eks::aws:cluster-access-policy/AmazonEKSViewPolicy" {
+		t.Errorf("test-ci-1: got policy %v", namespaceScope["policyArn"])
+	}
+}
+
+func TestServiceAccountStatement(t *testing.T) {
+	issuer := "oidc.eks.eu-central-1.amazonaws.com/id/EXAMPLE"
+	tests := []struct {
+		serviceAccount string
+		operator       string
+	}{
+		{"karpenter", "StringEquals"},
+		{"*", "StringLike"},
+		{"app-*", "StringLike"},
+	}
+	for _, tt := range tests {
+		statement := serviceAccountStatement("arn:aws:iam::123456789012:oidc-provider/"+issuer, issuer, "kube-system", tt.serviceAccount)
+		condition := statement["Condition"].(map[string]interface{})
+		subjects, ok := condition[tt.operator].(map[string]string)
+		if !ok {
+			t.Fatalf("%s: no %s condition in %v", tt.serviceAccount, tt.operator, condition)
+		}
+		if subjects[issuer+":sub"] != "system:serviceaccount:kube-system:"+tt.serviceAccount {
+			t.Errorf("%s: got subject %q", tt.serviceAccount, subjects[issuer+":sub"])
+		}
+		audiences := condition["StringEquals"].(map[string]string)
+		if audiences[issuer+":aud"] != "sts.amazonaws.com" {
+			t.Errorf("%s: got audience %q", tt.serviceAccount, audiences[issuer+":aud"])
+		}
+	}
+}
+
+func TestCreateServiceAccountRolePodIdentity(t *testing.T) {
+	m := newMocks()
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cluster, err := testCluster(ctx)
+		if err != nil {
+			return err
+		}
+		o := &EksCreateOutPut{Cluster: cluster}
+		if _, err := o.CreateServiceAccountRole(ctx, "irsa-only", ServiceAccountRole{Namespace: "apps", ServiceAccount: "web"}); err == nil {
+			t.Error("an IRSA role without an OIDC provider should fail")
+		}
+		if _, err := o.CreateServiceAccountRole(ctx, "wildcard", ServiceAccountRole{Namespace: "apps", ServiceAccount: "web-*", PodIdentity: true}); err == nil {
+			t.Error("a Pod Identity association with a wildcard should fail")
+		}
+		_, err = o.CreateServiceAccountRole(ctx, "web", ServiceAccountRole{Namespace: "apps", ServiceAccount: "web", PodIdentity: true})
+		return err
+	}, pulumi.WithMocks("project", "stack", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	association := m.inputs("aws:eks/podIdentityAssociation:PodIdentityAssociation", "web")
+	if association == nil {
+		t.Fatal("pod identity association web was not created")
+	}
+	if association["namespace"].StringValue() != "apps" || association["serviceAccount"].StringValue() != "web" {
+		t.Errorf("web: got %v", association)
+	}
+	trust := m.inputs("aws:iam/role:Role", "web")["assumeRolePolicy"].StringValue()
+	if !strings.Contains(trust, "pods.eks.amazonaws.com") || !strings.Contains(trust, "sts:TagSession") {
+		t.Errorf("web: got trust policy %s", trust)
+	}
+}
+
+func TestRenderAwsAuth(t *testing.T) {
+	foreign := "- rolearn: arn:aws:iam::123456789012:role/admin\n  username: admin\n  groups: [system:masters]\n"
+	tests := []struct {
+		name     string
+		existing map[string]string
+		owned    string
+		mapRoles []AwsAuthRole
+		want     []string
+		dropped  []string
+	}{
+		{
+			name: "new",
+			want: []string{"role/node", "role/fargate", "system:node-proxier"},
+		},
+		{
+			name:     "keeps entries of others",
+			existing: map[string]string{"mapRoles": foreign},
+			want:     []string{"role/admin", "system:masters", "role/node"},
+		},
+		{
+			name:     "drops entries removed from AwsAuth",
+			existing: map[string]string{"mapRoles": foreign + "- rolearn: arn:aws:iam::123456789012:role/removed\n  username: removed\n"},
+			owned:    "mapRoles:\n  - rolearn: arn:aws:iam::123456789012:role/removed\n",
+			want:     []string{"role/admin", "role/node"},
+			dropped:  []string{"role/removed"},
+		},
+		{
+			name:     "last mapping wins",
+			existing: map[string]string{"mapRoles": foreign},
+			mapRoles: []AwsAuthRole{{RoleArn: "arn:aws:iam::123456789012:role/admin", Username: "ops", Groups: []string{"ops"}}},
+			want:     []string{"role/admin", "username: ops"},
+			dropped:  []string{"system:masters"},
+		},
+	}
+	for _, tt := range tests {
+		e := &Eks{Name: "test", AwsAuth: AwsAuth{Enabled: true, MapRoles: tt.mapRoles}}
+		data, owned, err := e.renderAwsAuth(tt.existing, tt.owned, []string{"arn:aws:iam::123456789012:role/node"}, []string{"arn:aws:iam::123456789012:role/fargate"})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(data["mapRoles"], want) {
+				t.Errorf("%s: %q not in mapRoles\n%s", tt.name, want, data["mapRoles"])
+			}
+		}
+		for _, dropped := range tt.dropped {
+			if strings.Contains(data["mapRoles"], dropped) {
+				t.Errorf("%s: %q still in mapRoles\n%s", tt.name, dropped, data["mapRoles"])
+			}
+		}
+		if strings.Contains(owned, "role/admin") != (len(tt.mapRoles) > 0) {
+			t.Errorf("%s: got owned mappings\n%s", tt.name, owned)
+		}
+		if data["mapUsers"] != "[]\n" || data["mapAccounts"] != "[]\n" {
+			t.Errorf("%s: got users %q and accounts %q", tt.name, data["mapUsers"], data["mapAccounts"])
+		}
+	}
+}
+
+func TestRenderKubeconfig(t *testing.T) {
+	config, err := renderKubeconfig("test", "https://test", "Y2VydA==", "eu-central-1", "ops", "arn:aws:iam::123456789012:role/deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"--region\n", "- eu-central-1\n", "- --profile\n", "- ops\n", "- --role-arn\n", "server: https://test\n"} {
+		if !strings.Contains(config, want) {
+			t.Errorf("%q not in kubeconfig\n%s", want, config)
+		}
+	}
+}
+
+func TestCreateAwsAuth(t *testing.T) {
+	tests := []struct {
+		name              string
+		managedNodeGroups map[string]NodeGroup
+		read              bool
+	}{
+		{name: "created", read: false},
+		{name: "patched", managedNodeGroups: map[string]NodeGroup{"default": {}}, read: true},
+	}
+	for _, tt := range tests {
+		m := newMocks()
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			cluster, err := testCluster(ctx)
+			if err != nil {
+				return err
+			}
+			e := &Eks{Name: "test", ManagedNodeGroups: tt.managedNodeGroups, AwsAuth: AwsAuth{Enabled: true}}
+			nodeRoleArn := pulumi.String("arn:aws:iam::123456789012:role/node").ToStringOutput()
+			_, err = e.createAwsAuth(ctx, cluster, []pulumi.StringOutput{nodeRoleArn}, nil, nil)
+			return err
+		}, pulumi.WithMocks("project", "stack", m))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if m.has("kubernetes:core/v1:ConfigMap", "test-aws-auth-existing") != tt.read {
+			t.Errorf("%s: expected read %v", tt.name, tt.read)
+		}
+		patch := m.inputs("kubernetes:core/v1:ConfigMapPatch", "test-aws-auth")
+		if patch == nil {
+			t.Fatalf("%s: aws-auth patch was not created", tt.name)
+		}
+		annotations := patch["metadata"].ObjectValue()["annotations"].ObjectValue()
+		if annotations["pulumi.com/patchForce"].StringValue() != "true" {
+			t.Errorf("%s: got annotations %v", tt.name, annotations)
+		}
+		mapRoles := patch["data"].ObjectValue()["mapRoles"].StringValue()
+		if !strings.Contains(mapRoles, "role/node") {
+			t.Errorf("%s: node role not in mapRoles\n%s", tt.name, mapRoles)
+		}
+		if strings.Contains(mapRoles, "role/admin") != tt.read || strings.Contains(mapRoles, "role/removed") {
+			t.Errorf("%s: got mapRoles\n%s", tt.name, mapRoles)
+		}
+	}
+}
+
+func TestValidateKarpenter(t *testing.T) {
+	tests := []struct {
+		name string
+		eks  Eks
+		ok   bool
+	}{
+		{"irsa and aws-auth", Eks{Name: "test", AwsAuth: AwsAuth{Enabled: true}}, true},
+		{"pod identity and access entries", Eks{Name: "test", Irsa: irsa{Disabled: true}, Karpenter: Karpenter{PodIdentity: true}, AccessConfig: AccessConfig{AuthenticationMode: "API"}}, true},
+		{"no irsa", Eks{Name: "test", Irsa: irsa{Disabled: true}, AwsAuth: AwsAuth{Enabled: true}}, false},
+		{"no node role mapping", Eks{Name: "test"}, false},
+		{"config map mode", Eks{Name: "test", AccessConfig: AccessConfig{AuthenticationMode: "CONFIG_MAP"}}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.eks.validateKarpenter(); (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+}
+
+func TestCreateKarpenter(t *testing.T) {
+	tests := []struct {
+		name        string
+		eks         Eks
+		accessEntry bool
+		podIdentity bool
+	}{
+		{
+			name: "irsa and aws-auth",
+			eks:  Eks{Name: "test", Karpenter: Karpenter{Enabled: true}, AwsAuth: AwsAuth{Enabled: true}},
+		},
+		{
+			name:        "pod identity and access entries",
+			eks:         Eks{Name: "test", Irsa: irsa{Disabled: true}, Karpenter: Karpenter{Enabled: true, PodIdentity: true}, AccessConfig: AccessConfig{AuthenticationMode: "API"}},
+			accessEntry: true,
+			podIdentity: true,
+		},
+	}
+	for _, tt := range tests {
+		m := newMocks()
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			cluster, err := testCluster(ctx)
+			if err != nil {
+				return err
+			}
+			oidcProviderArn := pulumi.String("arn:aws:iam::123456789012:oidc-provider/oidc.eks.eu-central-1.amazonaws.com/id/EXAMPLE").ToStringOutput()
+			oidcProviderUrl := pulumi.String("oidc.eks.eu-central-1.amazonaws.com/id/EXAMPLE").ToStringOutput()
+			_, err = tt.eks.CreateKarpenter(ctx, cluster, oidcProviderArn, oidcProviderUrl)
+			return err
+		}, pulumi.WithMocks("project", "stack", m))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if m.has("aws:eks/accessEntry:AccessEntry", "test-karpenter-node") != tt.accessEntry {
+			t.Errorf("%s: expected node access entry %v", tt.name, tt.accessEntry)
+		}
+		if m.has("aws:eks/podIdentityAssociation:PodIdentityAssociation", "test-karpenter") != tt.podIdentity {
+			t.Errorf("%s: expected pod identity association %v", tt.name, tt.podIdentity)
+		}
+		trust := m.inputs("aws:iam/role:Role", "test-karpenter")["assumeRolePolicy"].StringValue()
+		if strings.Contains(trust, "AssumeRoleWithWebIdentity") == tt.podIdentity || strings.Contains(trust, "pods.eks.amazonaws.com") != tt.podIdentity {
+			t.Errorf("%s: got trust policy %s", tt.name, trust)
+		}
+		for _, event := range []string{"health", "spot-interruption", "rebalance", "instance-state-change"} {
+			if !m.has("aws:cloudwatch/eventRule:EventRule", "test-karpenter-"+event) {
+				t.Errorf("%s: event rule %s was not created", tt.name, event)
+			}
+		}
+	}
+}
+
+func TestCreateNodeRole(t *testing.T) {
+	existing := pulumi.String("arn:aws:iam::123456789012:role/existing")
+	tests := []struct {
+		name string
+		eks  Eks
+		role bool
+	}{
+		{"no node groups", Eks{Name: "test"}, true},
+		{"shared role", Eks{Name: "test", ManagedNodeGroups: map[string]NodeGroup{"a": {}, "b": {ExistingNodeRoleArn: existing}}}, true},
+		{"own roles", Eks{Name: "test", ManagedNodeGroups: map[string]NodeGroup{"a": {ExistingNodeRoleArn: existing}, "b": {ExistingNodeRoleArn: existing}}}, false},
+		{"self-managed", Eks{Name: "test", ManagedNodeGroups: map[string]NodeGroup{"a": {ExistingNodeRoleArn: existing}}, SelfManagedNodeGroups: map[string]SelfManagedNodeGroup{"b": {}}}, true},
+	}
+	for _, tt := range tests {
+		m := newMocks()
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			_, _, err := tt.eks.createNodeRole(ctx)
+			return err
+		}, pulumi.WithMocks("project", "stack", m))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if m.has("aws:iam/role:Role", "test-node") != tt.role {
+			t.Errorf("%s: expected node role %v", tt.name, tt.role)
+		}
+	}
+}
+
+func TestCreateSelfManagedNodeGroups(t *testing.T) {
+	m := newMocks()
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cluster, err := testCluster(ctx)
+		if err != nil {
+			return err
+		}
+		e := &Eks{Name: "test", AwsAuth: AwsAuth{Enabled: true}, SelfManagedNodeGroups: map[string]SelfManagedNodeGroup{
+			"workers": {
+				InstanceTypes: []string{"m6i.large", "m5.large"},
+				KubeletFlags:  []string{"--node-labels=pool=workers"},
+				MaxSize:       pulumi.Int(3),
+				MinSize:       pulumi.Int(1),
+				Tags:          pulumi.StringMap{"team": pulumi.String("platform")},
+			},
+		}}
+		_, err = e.CreateSelfManagedNodeGroups(ctx, cluster, pulumi.ID("sg-1").ToIDOutput(), pulumi.String("test-node").ToStringOutput())
+		return err
+	}, pulumi.WithMocks("project", "stack", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.has("aws:ssm/getParameter:getParameter", "/aws/service/eks/optimized-ami/1.33/amazon-linux-2023/x86_64/standard/recommended/image_id") {
+		t.Error("the AL2023 AMI was not looked up")
+	}
+	template := m.inputs("aws:ec2/launchTemplate:LaunchTemplate", "test-workers")
+	if template == nil {
+		t.Fatal("launch template test-workers was not created")
+	}
+	userData, err := base64.StdEncoding.DecodeString(template["userData"].StringValue())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Content-Type: application/node.eks.aws", "kind: NodeConfig", "cidr: 172.20.0.0/16", "--node-labels=pool=workers"} {
+		if !strings.Contains(string(userData), want) {
+			t.Errorf("%q not in user data\n%s", want, userData)
+		}
+	}
+	if !template["iamInstanceProfile"].ObjectValue().HasValue("arn") {
+		t.Errorf("got instance profile %v", template["iamInstanceProfile"])
+	}
+
+	group := m.inputs("aws:autoscaling/group:Group", "test-workers")
+	if group == nil {
+		t.Fatal("auto scaling group test-workers was not created")
+	}
+	tags := map[string]string{}
+	for _, tag := range group["tags"].ArrayValue() {
+		if !tag.ObjectValue()["propagateAtLaunch"].BoolValue() {
+			t.Errorf("tag %v is not propagated at launch", tag)
+		}
+		tags[tag.ObjectValue()["key"].StringValue()] = tag.ObjectValue()["value"].StringValue()
+	}
+	if tags["Name"] != "test-workers" || tags["kubernetes.io/cluster/test"] != "owned" || tags["team"] != "platform" {
+		t.Errorf("got tags %v", tags)
+	}
+	instanceRefresh := group["instanceRefresh"].ObjectValue()
+	if instanceRefresh["strategy"].StringValue() != "Rolling" || instanceRefresh["preferences"].ObjectValue()["minHealthyPercentage"].NumberValue() != 66 {
+		t.Errorf("got instance refresh %v", instanceRefresh)
+	}
+	policy := group["mixedInstancesPolicy"].ObjectValue()
+	if policy["instancesDistribution"].ObjectValue()["onDemandPercentageAboveBaseCapacity"].NumberValue() != 100 {
+		t.Errorf("got instances distribution %v", policy["instancesDistribution"])
+	}
+	if overrides := policy["launchTemplate"].ObjectValue()["overrides"].ArrayValue(); len(overrides) != 2 {
+		t.Errorf("got overrides %v", overrides)
+	}
+}
+
+func TestCreateSelfManagedNodeGroupsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		eks  Eks
+	}{
+		{"no node role mapping", Eks{Name: "test", SelfManagedNodeGroups: map[string]SelfManagedNodeGroup{"workers": {}}}},
+		{"unknown AMI family", Eks{Name: "test", AwsAuth: AwsAuth{Enabled: true}, SelfManagedNodeGroups: map[string]SelfManagedNodeGroup{"workers": {AmiFamily: "Bottlerocket"}}}},
+		{"AL2 on 1.33", Eks{Name: "test", AwsAuth: AwsAuth{Enabled: true}, SelfManagedNodeGroups: map[string]SelfManagedNodeGroup{"workers": {AmiFamily: "AL2"}}}},
+	}
+	for _, tt := range tests {
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			cluster, err := testCluster(ctx)
+			if err != nil {
+				return err
+			}
+			_, err = tt.eks.CreateSelfManagedNodeGroups(ctx, cluster, pulumi.ID("sg-1").ToIDOutput(), pulumi.String("test-node").ToStringOutput())
+			return err
+		}, pulumi.WithMocks("project", "stack", newMocks()))
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestIdentityProvider(t *testing.T) {
+	corp := IdentityProvider{Name: "corp", IssuerUrl: pulumi.String("https://idp.example.com"), ClientId: pulumi.String("eks")}
+	tests := []struct {
+		name string
+		eks  Eks
+		want string
+		ok   bool
+	}{
+		{"none", Eks{Name: "test"}, "", true},
+		{"single", Eks{Name: "test", IdentityProvider: &corp}, "corp", true},
+		{"deprecated map", Eks{Name: "test", IdentityProviders: map[string]IdentityProvider{"legacy": {}}}, "legacy", true},
+		{"two in the map", Eks{Name: "test", IdentityProviders: map[string]IdentityProvider{"a": {}, "b": {}}}, "", false},
+		{"both fields", Eks{Name: "test", IdentityProvider: &corp, IdentityProviders: map[string]IdentityProvider{"a": {}}}, "", false},
+		{"no name", Eks{Name: "test", IdentityProvider: &IdentityProvider{}}, "", false},
+	}
+	for _, tt := range tests {
+		identityProvider, err := tt.eks.identityProvider()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.name, err)
+			continue
+		}
+		name := ""
+		if identityProvider != nil {
+			name = identityProvider.Name
+		}
+		if name != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, name, tt.want)
+		}
+	}
+
+	m := newMocks()
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cluster, err := testCluster(ctx)
+		if err != nil {
+			return err
+		}
+		e := &Eks{Name: "test", IdentityProvider: &corp}
+		_, err = e.CreateIdentityProvider(ctx, cluster)
+		return err
+	}, pulumi.WithMocks("project", "stack", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := m.inputs("aws:eks/identityProviderConfig:IdentityProviderConfig", "test-corp")
+	if config == nil || config["oidc"].ObjectValue()["identityProviderConfigName"].StringValue() != "corp" {
+		t.Errorf("got identity provider config %v", config)
+	}
+}
+
+func TestCheckIrsaEnabled(t *testing.T) {
+	enabled, disabled := true, false
+	tests := []struct {
+		name    string
+		enabled *bool
+		ok      bool
+	}{
+		{"unset", nil, true},
+		{"true", &enabled, true},
+		{"false", &disabled, false},
+	}
+	for _, tt := range tests {
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			e := &Eks{Name: "test", Irsa: irsa{Enabled: tt.enabled}}
+			return e.checkIrsaEnabled(ctx)
+		}, pulumi.WithMocks("project", "stack", newMocks()))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+}
+
+func TestPrefixListRules(t *testing.T) {
+	prefixListIds := pulumi.StringArray{pulumi.String("pl-offices")}
+	tests := []struct {
+		name string
+		rule PrefixListRule
+		kind string
+	}{
+		{"ingress by default", PrefixListRule{FromPort: 443, ToPort: 443, Protocol: "tcp", PrefixListIds: prefixListIds}, "ingress"},
+		{"egress", PrefixListRule{Kind: "egress", FromPort: 0, ToPort: 0, Protocol: "-1", PrefixListIds: prefixListIds}, "egress"},
+	}
+	for _, tt := range tests {
+		rules := prefixListRules([]PrefixListRule{tt.rule})
+		if len(rules) != 1 {
+			t.Fatalf("%s: got %d rules", tt.name, len(rules))
+		}
+		if rules[0].kind != tt.kind {
+			t.Errorf("%s: got kind %s, want %s", tt.name, rules[0].kind, tt.kind)
+		}
+		args := createSecurityGroupRule(rules[0])
+		if len(args.PrefixListIds.(pulumi.StringArray)) != 1 || args.SourceSecurityGroupId != nil || args.CidrBlocks != nil {
+			t.Errorf("%s: the rule is not limited to the prefix list: %+v", tt.name, args)
+		}
+	}
+}
+
+func TestCreateFargateProfiles(t *testing.T) {
+	m := newMocks()
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cluster, err := testCluster(ctx)
+		if err != nil {
+			return err
+		}
+		e := &Eks{
+			Name:                          "test",
+			FargateRoleAdditionalPolicies: []string{"arn:aws:iam::123456789012:policy/logs"},
+			FargateProfiles: map[string]FargateProfile{
+				"system": {Selectors: []FargateProfileSelector{{Namespace: "kube-system", Labels: pulumi.StringMap{"k8s-app": pulumi.String("kube-dns")}}}},
+				"apps":   {Name: "workloads", Selectors: []FargateProfileSelector{{Namespace: "apps"}}},
+			},
+		}
+		_, err = e.CreateFargateProfiles(ctx, cluster)
+		return err
+	}, pulumi.WithMocks("project", "stack", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trust := m.inputs("aws:iam/role:Role", "test-fargate")["assumeRolePolicy"].StringValue()
+	if !strings.Contains(trust, "arn:aws:eks:eu-central-1:123456789012:fargateprofile/test/*") {
+		t.Errorf("the pod execution role is not limited to the Fargate profiles of the cluster: %s", trust)
+	}
+	if m.inputs("aws:iam/rolePolicyAttachment:RolePolicyAttachment", "test-fargate-1")["policyArn"].StringValue() != "arn:aws:iam::123456789012:policy/logs" {
+		t.Error("the additional policy is not attached")
+	}
+	if m.inputs("aws:eks/fargateProfile:FargateProfile", "test-system")["fargateProfileName"].StringValue() != "system" {
+		t.Error("a profile without a name is not named after its key")
+	}
+	if m.inputs("aws:eks/fargateProfile:FargateProfile", "test-workloads")["fargateProfileName"].StringValue() != "workloads" {
+		t.Error("a named profile does not keep its name")
+	}
+}
+
+func TestCreateEksNodeGroups(t *testing.T) {
+	m := newMocks()
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		cluster, err := testCluster(ctx)
+		if err != nil {
+			return err
+		}
+		e := &Eks{
+			Name: "test",
+			ManagedNodeGroups: map[string]NodeGroup{
+				"general": {InstanceTypes: pulumi.StringArray{pulumi.String("m6i.large")}, MinSize: pulumi.Int(1), MaxSize: pulumi.Int(3), DesiredSize: pulumi.Int(2)},
+				"gpu":     {Name: "gpu-workers", ExistingNodeRoleArn: "arn:aws:iam::123456789012:role/gpu"},
+			},
+		}
+		_, err = e.CreateEksNodeGroups(ctx, cluster.ID(), cluster.ID(), cluster.Name, pulumi.String("arn:aws:iam::123456789012:role/nodes"))
+		return err
+	}, pulumi.WithMocks("project", "stack", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	general := m.inputs("aws:eks/nodeGroup:NodeGroup", "general")
+	if types := general["instanceTypes"].ArrayValue(); len(types) != 1 || types[0].StringValue() != "m6i.large" {
+		t.Errorf("got instance types %v", general["instanceTypes"])
+	}
+	if general["nodeRoleArn"].StringValue() != "arn:aws:iam::123456789012:role/nodes" || general["nodeGroupName"].StringValue() != "general" {
+		t.Errorf("got node group %v", general)
+	}
+	gpu := m.inputs("aws:eks/nodeGroup:NodeGroup", "gpu-workers")
+	if gpu["nodeRoleArn"].StringValue() != "arn:aws:iam::123456789012:role/gpu" || gpu["nodeGroupName"].StringValue() != "gpu-workers" {
+		t.Errorf("got node group %v", gpu)
+	}
+	if !m.has("aws:ec2/securityGroupRule:SecurityGroupRule", "test-node-0") {
+		t.Error("the node security group rules are not named after the cluster")
+	}
+}
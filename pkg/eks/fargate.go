@@ -0,0 +1,98 @@
+package eks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// CreateFargateProfiles creates the pod execution role and one Fargate profile
+// per entry of FargateProfiles. EKS rejects concurrent profile operations on a
+// cluster, so every profile depends on the previous one.
+func (e *Eks) CreateFargateProfiles(ctx *pulumi.Context, cluster *eks.Cluster) (*FargateCreateOutPut, error) {
+	fargateCreateOutput := &FargateCreateOutPut{
+		Profiles: map[string]*eks.FargateProfile{},
+	}
+	if len(e.FargateProfiles) == 0 {
+		return fargateCreateOutput, nil
+	}
+
+	// Pod execution role, limited to the Fargate profiles of the cluster
+	assumeRolePolicy := cluster.Arn.ApplyT(func(clusterArn string) string {
+		profileArn := strings.Replace(clusterArn, ":cluster/", ":fargateprofile/", 1) + "/*"
+		return fmt.Sprintf(`{
+			"Version": "2012-10-17",
+			"Statement": [{
+				"Effect": "Allow",
+				"Principal": {
+					"Service": "eks-fargate-pods.amazonaws.com"
+				},
+				"Action": "sts:AssumeRole",
+				"Condition": {
+					"ArnLike": {
+						"aws:SourceArn": "%s"
+					}
+				}
+			}]
+		}`, profileArn)
+	}).(pulumi.StringOutput)
+	podExecutionRole, err := iam.NewRole(ctx, e.Name+"-fargate", &iam.RoleArgs{
+		AssumeRolePolicy: assumeRolePolicy,
+		Tags:             e.Tags,
+	})
+	if err != nil {
+		return fargateCreateOutput, err
+	}
+	fargateCreateOutput.PodExecutionRoleArn = podExecutionRole.Arn
+
+	podExecutionPolicies := append([]string{
+		"arn:aws:iam::aws:policy/AmazonEKSFargatePodExecutionRolePolicy",
+	}, e.FargateRoleAdditionalPolicies...)
+	for index, podExecutionPolicy := range podExecutionPolicies {
+		_, err := iam.NewRolePolicyAttachment(ctx, e.Name+"-fargate-"+strconv.Itoa(index), &iam.RolePolicyAttachmentArgs{
+			Role:      podExecutionRole.Name,
+			PolicyArn: pulumi.String(podExecutionPolicy),
+		})
+		if err != nil {
+			return fargateCreateOutput, err
+		}
+	}
+
+	// Fargate profiles, one after another
+	var previous pulumi.Resource = podExecutionRole
+	for _, key := range sortedKeys(e.FargateProfiles) {
+		profile := e.FargateProfiles[key]
+		name := profile.Name
+		if name == "" {
+			name = key
+		}
+
+		selectors := eks.FargateProfileSelectorArray{}
+		for _, selector := range profile.Selectors {
+			selectors = append(selectors, eks.FargateProfileSelectorArgs{
+				Namespace: pulumi.String(selector.Namespace),
+				Labels:    selector.Labels,
+			})
+		}
+
+		fargateProfile, err := eks.NewFargateProfile(ctx, e.Name+"-"+name, &eks.FargateProfileArgs{
+			ClusterName:         cluster.Name,
+			FargateProfileName:  pulumi.String(name),
+			PodExecutionRoleArn: podExecutionRole.Arn,
+			Selectors:           selectors,
+			SubnetIds:           profile.SubnetIds,
+			Tags:                mergeTags(e.Tags, profile.Tags),
+		}, pulumi.DependsOn([]pulumi.Resource{previous}))
+		if err != nil {
+			return fargateCreateOutput, err
+		}
+		fargateCreateOutput.Profiles[key] = fargateProfile
+		previous = fargateProfile
+	}
+
+	return fargateCreateOutput, nil
+}
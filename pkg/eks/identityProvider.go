@@ -0,0 +1,77 @@
+package eks
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// identityProvider returns the OIDC identity provider of the cluster, given by
+// IdentityProvider or by the deprecated IdentityProviders keyed by its config
+// name. EKS allows a single OIDC identity provider config per cluster.
+func (e *Eks) identityProvider() (*IdentityProvider, error) {
+	if e.IdentityProvider != nil && len(e.IdentityProviders) > 0 {
+		return nil, fmt.Errorf("cluster %s: set IdentityProvider or IdentityProviders, not both", e.Name)
+	}
+	if len(e.IdentityProviders) > 1 {
+		return nil, fmt.Errorf("cluster %s: EKS supports one OIDC identity provider, got %d", e.Name, len(e.IdentityProviders))
+	}
+	for name, identityProvider := range e.IdentityProviders {
+		identityProvider.Name = name
+		return &identityProvider, nil
+	}
+	if e.IdentityProvider != nil && e.IdentityProvider.Name == "" {
+		return nil, fmt.Errorf("cluster %s: IdentityProvider needs a Name", e.Name)
+	}
+	return e.IdentityProvider, nil
+}
+
+// CreateIdentityProvider associates the OIDC identity provider of the cluster,
+// if any.
+func (e *Eks) CreateIdentityProvider(ctx *pulumi.Context, cluster *eks.Cluster) (*eks.IdentityProviderConfig, error) {
+	identityProvider, err := e.identityProvider()
+	if err != nil || identityProvider == nil {
+		return nil, err
+	}
+
+	oidc := &eks.IdentityProviderConfigOidcArgs{
+		IdentityProviderConfigName: pulumi.String(identityProvider.Name),
+		IssuerUrl:                  identityProvider.IssuerUrl,
+		ClientId:                   identityProvider.ClientId,
+		RequiredClaims:             identityProvider.RequiredClaims,
+	}
+	if identityProvider.UsernameClaim != "" {
+		oidc.UsernameClaim = identityProvider.UsernameClaim
+	}
+	if identityProvider.UsernamePrefix != "" {
+		oidc.UsernamePrefix = identityProvider.UsernamePrefix
+	}
+	if identityProvider.GroupsClaim != "" {
+		oidc.GroupsClaim = identityProvider.GroupsClaim
+	}
+	if identityProvider.GroupsPrefix != "" {
+		oidc.GroupsPrefix = identityProvider.GroupsPrefix
+	}
+
+	return eks.NewIdentityProviderConfig(ctx, e.Name+"-"+identityProvider.Name, &eks.IdentityProviderConfigArgs{
+		ClusterName: cluster.Name,
+		Oidc:        oidc,
+		Tags:        mergeTags(e.Tags, identityProvider.Tags),
+	})
+}
+
+// CreateIdentityProviders associates the OIDC identity provider of the cluster
+// and returns it keyed by its config name.
+//
+// Deprecated: use CreateIdentityProvider.
+func (e *Eks) CreateIdentityProviders(ctx *pulumi.Context, cluster *eks.Cluster) (map[string]*eks.IdentityProviderConfig, error) {
+	identityProviderConfigs := map[string]*eks.IdentityProviderConfig{}
+	identityProviderConfig, err := e.CreateIdentityProvider(ctx, cluster)
+	if err != nil || identityProviderConfig == nil {
+		return identityProviderConfigs, err
+	}
+	identityProvider, _ := e.identityProvider()
+	identityProviderConfigs[identityProvider.Name] = identityProviderConfig
+	return identityProviderConfigs, nil
+}
@@ -0,0 +1,91 @@
+package eks
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// CreateServiceAccountRole creates an IAM role that the service account of
+// the given namespace assumes through the cluster OIDC provider. The returned
+// ARN goes into the eks.amazonaws.com/role-arn annotation of the service
+// account. With PodIdentity the role also trusts EKS Pod Identity, next to
+// IRSA when the cluster has it, and is associated with the service account, so
+// workloads can migrate side by side.
+func (o *EksCreateOutPut) CreateServiceAccountRole(ctx *pulumi.Context, name string, role ServiceAccountRole) (pulumi.StringOutput, error) {
+	if role.Namespace == "" || role.ServiceAccount == "" {
+		return pulumi.StringOutput{}, fmt.Errorf("service account role %s: set a Namespace and a ServiceAccount", name)
+	}
+
+	var assumeRolePolicy pulumi.StringInput
+	switch {
+	case o.OidcProviderArn.OutputState != nil:
+		assumeRolePolicy = serviceAccountAssumeRolePolicy(o.OidcProviderArn, o.OidcProviderUrl, role.Namespace, role.ServiceAccount, role.PodIdentity)
+	case role.PodIdentity:
+		podIdentityPolicy, err := podIdentityAssumeRolePolicy()
+		if err != nil {
+			return pulumi.StringOutput{}, err
+		}
+		assumeRolePolicy = pulumi.String(podIdentityPolicy)
+	default:
+		return pulumi.StringOutput{}, fmt.Errorf("service account role %s: IRSA is not enabled on the cluster", name)
+	}
+
+	roleArgs := &iam.RoleArgs{
+		AssumeRolePolicy: assumeRolePolicy,
+		Tags:             role.Tags,
+	}
+	if role.Description != "" {
+		roleArgs.Description = role.Description
+	}
+	if role.PermissionsBoundary != "" {
+		roleArgs.PermissionsBoundary = role.PermissionsBoundary
+	}
+	inlinePolicies := iam.RoleInlinePolicyArray{}
+	for _, policyName := range sortedKeys(role.InlinePolicies) {
+		inlinePolicies = append(inlinePolicies, iam.RoleInlinePolicyArgs{
+			Name:   pulumi.String(policyName),
+			Policy: role.InlinePolicies[policyName],
+		})
+	}
+	if len(inlinePolicies) > 0 {
+		roleArgs.InlinePolicies = inlinePolicies
+	}
+	serviceAccountRole, err := iam.NewRole(ctx, name, roleArgs)
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+
+	for index, policyArn := range role.PolicyArns {
+		_, err := iam.NewRolePolicyAttachment(ctx, name+"-"+strconv.Itoa(index), &iam.RolePolicyAttachmentArgs{
+			Role:      serviceAccountRole.Name,
+			PolicyArn: pulumi.String(policyArn),
+		})
+		if err != nil {
+			return pulumi.StringOutput{}, err
+		}
+	}
+
+	if role.PodIdentity {
+		_, err := o.CreatePodIdentityAssociation(ctx, name, role.Namespace, role.ServiceAccount, serviceAccountRole.Arn, role.Tags)
+		if err != nil {
+			return pulumi.StringOutput{}, err
+		}
+	}
+
+	return serviceAccountRole.Arn, nil
+}
+
+// checkIrsaEnabled rejects the deprecated Irsa.Enabled when it is false, as it
+// no longer turns IRSA off, and warns when it is true.
+func (e *Eks) checkIrsaEnabled(ctx *pulumi.Context) error {
+	if e.Irsa.Enabled == nil {
+		return nil
+	}
+	if !*e.Irsa.Enabled {
+		return fmt.Errorf("cluster %s: Irsa.Enabled is deprecated and no longer turns IRSA off, set Irsa.Disabled instead", e.Name)
+	}
+	return ctx.Log.Warn("cluster "+e.Name+": Irsa.Enabled is deprecated, IRSA is created unless Irsa.Disabled is set", nil)
+}
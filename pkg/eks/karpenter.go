@@ -0,0 +1,368 @@
+package eks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudwatch"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/sqs"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// CreateKarpenter creates the AWS side of Karpenter: the controller role, used
+// through IRSA or Pod Identity, the node role and its instance profile and the
+// interruption queue fed by EventBridge. The node role gets an EC2_LINUX access
+// entry when the cluster authenticates through access entries, otherwise
+// CreateEKS maps it through aws-auth. Karpenter discovers the node security
+// group and the subnets tagged karpenter.sh/discovery with Eks.Name, which
+// CreateEKS tags.
+func (e *Eks) CreateKarpenter(ctx *pulumi.Context, cluster *eks.Cluster, oidcProviderArn, oidcProviderUrl pulumi.StringOutput) (*KarpenterCreateOutPut, error) {
+	karpenterCreateOutput := &KarpenterCreateOutPut{}
+
+	partition, err := aws.GetPartition(ctx, nil, nil)
+	if err != nil {
+		return karpenterCreateOutput, err
+	}
+	region, err := aws.GetRegion(ctx, nil, nil)
+	if err != nil {
+		return karpenterCreateOutput, err
+	}
+
+	namespace := e.Karpenter.Namespace
+	if namespace == "" {
+		namespace = "kube-system"
+	}
+	serviceAccount := e.Karpenter.ServiceAccount
+	if serviceAccount == "" {
+		serviceAccount = "karpenter"
+	}
+
+	// Node role and instance profile
+	nodeRole, err := iam.NewRole(ctx, e.Name+"-karpenter-node", &iam.RoleArgs{
+		AssumeRolePolicy: pulumi.String(`{
+			"Version": "2012-10-17",
+			"Statement": [{
+				"Effect": "Allow",
+				"Principal": {
+					"Service": "ec2.amazonaws.com"
+				},
+				"Action": "sts:AssumeRole"
+			}]
+		}`),
+		Tags: e.Tags,
+	})
+	if err != nil {
+		return karpenterCreateOutput, err
+	}
+	nodePolicies := append([]string{
+		"arn:aws:iam::aws:policy/AmazonEKSWorkerNodePolicy",
+		"arn:aws:iam::aws:policy/AmazonEKS_CNI_Policy",
+		"arn:aws:iam::aws:policy/AmazonEC2ContainerRegistryReadOnly",
+		"arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore",
+	}, e.Karpenter.NodeRoleAdditionalPolicies...)
+	for index, nodePolicy := range nodePolicies {
+		_, err := iam.NewRolePolicyAttachment(ctx, e.Name+"-karpenter-node-"+strconv.Itoa(index), &iam.RolePolicyAttachmentArgs{
+			Role:      nodeRole.Name,
+			PolicyArn: pulumi.String(nodePolicy),
+		})
+		if err != nil {
+			return karpenterCreateOutput, err
+		}
+	}
+	karpenterCreateOutput.NodeRoleArn = nodeRole.Arn
+	karpenterCreateOutput.NodeRoleName = nodeRole.Name
+
+	instanceProfile, err := iam.NewInstanceProfile(ctx, e.Name+"-karpenter-node", &iam.InstanceProfileArgs{
+		Role: nodeRole.Name,
+		Tags: e.Tags,
+	})
+	if err != nil {
+		return karpenterCreateOutput, err
+	}
+	karpenterCreateOutput.InstanceProfileName = instanceProfile.Name
+
+	if e.hasAccessEntries() {
+		karpenterCreateOutput.NodeAccessEntry, err = eks.NewAccessEntry(ctx, e.Name+"-karpenter-node", &eks.AccessEntryArgs{
+			ClusterName:  cluster.Name,
+			PrincipalArn: nodeRole.Arn,
+			Type:         pulumi.String("EC2_LINUX"),
+			Tags:         e.Tags,
+		})
+		if err != nil {
+			return karpenterCreateOutput, err
+		}
+	}
+
+	// Interruption queue and EventBridge rules
+	queue, err := sqs.NewQueue(ctx, e.Name+"-karpenter", &sqs.QueueArgs{
+		MessageRetentionSeconds: pulumi.Int(300),
+		SqsManagedSseEnabled:    pulumi.Bool(true),
+		Tags:                    e.Tags,
+	})
+	if err != nil {
+		return karpenterCreateOutput, err
+	}
+	karpenterCreateOutput.QueueName = queue.Name
+
+	_, err = sqs.NewQueuePolicy(ctx, e.Name+"-karpenter", &sqs.QueuePolicyArgs{
+		QueueUrl: queue.Url,
+		Policy: queue.Arn.ApplyT(func(queueArn string) (string, error) {
+			return marshalPolicy([]map[string]interface{}{
+				{
+					"Sid":       "EventBridge",
+					"Effect":    "Allow",
+					"Principal": map[string]interface{}{"Service": []string{"events.amazonaws.com", "sqs.amazonaws.com"}},
+					"Action":    "sqs:SendMessage",
+					"Resource":  queueArn,
+				},
+				{
+					"Sid":       "DenyHTTP",
+					"Effect":    "Deny",
+					"Principal": "*",
+					"Action":    "sqs:*",
+					"Resource":  queueArn,
+					"Condition": map[string]interface{}{"Bool": map[string]string{"aws:SecureTransport": "false"}},
+				},
+			})
+		}).(pulumi.StringOutput),
+	})
+	if err != nil {
+		return karpenterCreateOutput, err
+	}
+
+	events := []struct {
+		name       string
+		source     string
+		detailType string
+	}{
+		{"health", "aws.health", "AWS Health Event"},
+		{"spot-interruption", "aws.ec2", "EC2 Spot Instance Interruption Warning"},
+		{"rebalance", "aws.ec2", "EC2 Instance Rebalance Recommendation"},
+		{"instance-state-change", "aws.ec2", "EC2 Instance State-change Notification"},
+	}
+	for _, event := range events {
+		eventPattern, err := json.Marshal(map[string][]string{
+			"source":      {event.source},
+			"detail-type": {event.detailType},
+		})
+		if err != nil {
+			return karpenterCreateOutput, err
+		}
+		rule, err := cloudwatch.NewEventRule(ctx, e.Name+"-karpenter-"+event.name, &cloudwatch.EventRuleArgs{
+			Description:  pulumi.String("Karpenter interruption handling for " + event.detailType),
+			EventPattern: pulumi.String(string(eventPattern)),
+			Tags:         e.Tags,
+		})
+		if err != nil {
+			return karpenterCreateOutput, err
+		}
+		_, err = cloudwatch.NewEventTarget(ctx, e.Name+"-karpenter-"+event.name, &cloudwatch.EventTargetArgs{
+			Rule:     rule.Name,
+			TargetId: pulumi.String("KarpenterInterruptionQueue"),
+			Arn:      queue.Arn,
+		})
+		if err != nil {
+			return karpenterCreateOutput, err
+		}
+	}
+
+	// Controller role
+	var assumeRolePolicy pulumi.StringInput
+	if e.Irsa.Disabled {
+		policy, err := podIdentityAssumeRolePolicy()
+		if err != nil {
+			return karpenterCreateOutput, err
+		}
+		assumeRolePolicy = pulumi.String(policy)
+	} else {
+		assumeRolePolicy = serviceAccountAssumeRolePolicy(oidcProviderArn, oidcProviderUrl, namespace, serviceAccount, e.Karpenter.PodIdentity)
+	}
+	controllerRole, err := iam.NewRole(ctx, e.Name+"-karpenter", &iam.RoleArgs{
+		AssumeRolePolicy: assumeRolePolicy,
+		Tags:             e.Tags,
+	})
+	if err != nil {
+		return karpenterCreateOutput, err
+	}
+	karpenterCreateOutput.ControllerRoleArn = controllerRole.Arn
+
+	if e.Karpenter.PodIdentity {
+		karpenterCreateOutput.PodIdentityAssociation, err = eks.NewPodIdentityAssociation(ctx, e.Name+"-karpenter", &eks.PodIdentityAssociationArgs{
+			ClusterName:    cluster.Name,
+			Namespace:      pulumi.String(namespace),
+			ServiceAccount: pulumi.String(serviceAccount),
+			RoleArn:        controllerRole.Arn,
+			Tags:           e.Tags,
+		})
+		if err != nil {
+			return karpenterCreateOutput, err
+		}
+	}
+
+	controllerPolicy := pulumi.All(cluster.Name, cluster.Arn, nodeRole.Arn, queue.Arn).ApplyT(func(args []interface{}) (string, error) {
+		return karpenterControllerPolicy(partition.Partition, region.Name, args[0].(string), args[1].(string), args[2].(string), args[3].(string))
+	}).(pulumi.StringOutput)
+	_, err = iam.NewRolePolicy(ctx, e.Name+"-karpenter", &iam.RolePolicyArgs{
+		Role:   controllerRole.Name,
+		Policy: controllerPolicy,
+	})
+	if err != nil {
+		return karpenterCreateOutput, err
+	}
+
+	return karpenterCreateOutput, nil
+}
+
+// validateKarpenter checks that the controller role can be assumed and that
+// the node role can be mapped.
+func (e *Eks) validateKarpenter() error {
+	if e.Irsa.Disabled && !e.Karpenter.PodIdentity {
+		return fmt.Errorf("karpenter of %s needs IRSA or Karpenter.PodIdentity", e.Name)
+	}
+	if !e.hasAccessEntries() && !e.AwsAuth.Enabled {
+		return fmt.Errorf("karpenter of %s needs the API authentication mode or AwsAuth.Enabled to map its node role", e.Name)
+	}
+	return nil
+}
+
+// karpenterControllerPolicy returns the controller policy of Karpenter, scoped
+// to the resources Karpenter launches for the cluster.
+func karpenterControllerPolicy(partition, region, clusterName, clusterArn, nodeRoleArn, queueArn string) (string, error) {
+	ec2Arn := func(resource string) string {
+		return fmt.Sprintf("arn:%s:ec2:%s:*:%s", partition, region, resource)
+	}
+	clusterTag := "aws:ResourceTag/kubernetes.io/cluster/" + clusterName
+	requestClusterTag := "aws:RequestTag/kubernetes.io/cluster/" + clusterName
+	launchedResources := []string{
+		ec2Arn("fleet/*"),
+		ec2Arn("instance/*"),
+		ec2Arn("volume/*"),
+		ec2Arn("network-interface/*"),
+		ec2Arn("launch-template/*"),
+		ec2Arn("spot-instances-request/*"),
+	}
+
+	return marshalPolicy([]map[string]interface{}{
+		{
+			"Sid":    "AllowScopedEC2InstanceAccessActions",
+			"Effect": "Allow",
+			"Action": []string{"ec2:RunInstances", "ec2:CreateFleet"},
+			"Resource": []string{
+				fmt.Sprintf("arn:%s:ec2:%s::image/*", partition, region),
+				fmt.Sprintf("arn:%s:ec2:%s::snapshot/*", partition, region),
+				ec2Arn("security-group/*"),
+				ec2Arn("subnet/*"),
+			},
+		},
+		{
+			"Sid":       "AllowScopedEC2LaunchTemplateAccessActions",
+			"Effect":    "Allow",
+			"Action":    []string{"ec2:RunInstances", "ec2:CreateFleet"},
+			"Resource":  ec2Arn("launch-template/*"),
+			"Condition": map[string]interface{}{"StringEquals": map[string]string{clusterTag: "owned"}, "StringLike": map[string]string{"aws:ResourceTag/karpenter.sh/nodepool": "*"}},
+		},
+		{
+			"Sid":       "AllowScopedEC2InstanceActionsWithTags",
+			"Effect":    "Allow",
+			"Action":    []string{"ec2:RunInstances", "ec2:CreateFleet", "ec2:CreateLaunchTemplate"},
+			"Resource":  launchedResources,
+			"Condition": map[string]interface{}{"StringEquals": map[string]string{requestClusterTag: "owned"}, "StringLike": map[string]string{"aws:RequestTag/karpenter.sh/nodepool": "*"}},
+		},
+		{
+			"Sid":      "AllowScopedResourceCreationTagging",
+			"Effect":   "Allow",
+			"Action":   "ec2:CreateTags",
+			"Resource": launchedResources,
+			"Condition": map[string]interface{}{
+				"StringEquals": map[string]interface{}{requestClusterTag: "owned", "ec2:CreateAction": []string{"RunInstances", "CreateFleet", "CreateLaunchTemplate"}},
+				"StringLike":   map[string]string{"aws:RequestTag/karpenter.sh/nodepool": "*"},
+			},
+		},
+		{
+			"Sid":       "AllowScopedResourceTagging",
+			"Effect":    "Allow",
+			"Action":    "ec2:CreateTags",
+			"Resource":  ec2Arn("instance/*"),
+			"Condition": map[string]interface{}{"StringEquals": map[string]string{clusterTag: "owned"}, "StringLike": map[string]string{"aws:ResourceTag/karpenter.sh/nodepool": "*"}},
+		},
+		{
+			"Sid":       "AllowScopedDeletion",
+			"Effect":    "Allow",
+			"Action":    []string{"ec2:TerminateInstances", "ec2:DeleteLaunchTemplate"},
+			"Resource":  []string{ec2Arn("instance/*"), ec2Arn("launch-template/*")},
+			"Condition": map[string]interface{}{"StringEquals": map[string]string{clusterTag: "owned"}, "StringLike": map[string]string{"aws:ResourceTag/karpenter.sh/nodepool": "*"}},
+		},
+		{
+			"Sid":    "AllowRegionalReadActions",
+			"Effect": "Allow",
+			"Action": []string{
+				"ec2:DescribeAvailabilityZones",
+				"ec2:DescribeImages",
+				"ec2:DescribeInstances",
+				"ec2:DescribeInstanceTypeOfferings",
+				"ec2:DescribeInstanceTypes",
+				"ec2:DescribeLaunchTemplates",
+				"ec2:DescribeSecurityGroups",
+				"ec2:DescribeSpotPriceHistory",
+				"ec2:DescribeSubnets",
+			},
+			"Resource":  "*",
+			"Condition": map[string]interface{}{"StringEquals": map[string]string{"aws:RequestedRegion": region}},
+		},
+		{
+			"Sid":      "AllowSSMReadActions",
+			"Effect":   "Allow",
+			"Action":   "ssm:GetParameter",
+			"Resource": fmt.Sprintf("arn:%s:ssm:%s::parameter/aws/service/*", partition, region),
+		},
+		{
+			"Sid":      "AllowPricingReadActions",
+			"Effect":   "Allow",
+			"Action":   "pricing:GetProducts",
+			"Resource": "*",
+		},
+		{
+			"Sid":      "AllowInterruptionQueueActions",
+			"Effect":   "Allow",
+			"Action":   []string{"sqs:DeleteMessage", "sqs:GetQueueUrl", "sqs:ReceiveMessage"},
+			"Resource": queueArn,
+		},
+		{
+			"Sid":       "AllowPassingInstanceRole",
+			"Effect":    "Allow",
+			"Action":    "iam:PassRole",
+			"Resource":  nodeRoleArn,
+			"Condition": map[string]interface{}{"StringEquals": map[string]string{"iam:PassedToService": "ec2.amazonaws.com"}},
+		},
+		{
+			"Sid":       "AllowScopedInstanceProfileCreationActions",
+			"Effect":    "Allow",
+			"Action":    []string{"iam:CreateInstanceProfile", "iam:TagInstanceProfile"},
+			"Resource":  fmt.Sprintf("arn:%s:iam::*:instance-profile/*", partition),
+			"Condition": map[string]interface{}{"StringEquals": map[string]string{requestClusterTag: "owned", "aws:RequestTag/topology.kubernetes.io/region": region}},
+		},
+		{
+			"Sid":       "AllowScopedInstanceProfileActions",
+			"Effect":    "Allow",
+			"Action":    []string{"iam:AddRoleToInstanceProfile", "iam:RemoveRoleFromInstanceProfile", "iam:DeleteInstanceProfile", "iam:TagInstanceProfile"},
+			"Resource":  fmt.Sprintf("arn:%s:iam::*:instance-profile/*", partition),
+			"Condition": map[string]interface{}{"StringEquals": map[string]string{clusterTag: "owned", "aws:ResourceTag/topology.kubernetes.io/region": region}},
+		},
+		{
+			"Sid":      "AllowInstanceProfileReadActions",
+			"Effect":   "Allow",
+			"Action":   "iam:GetInstanceProfile",
+			"Resource": "*",
+		},
+		{
+			"Sid":      "AllowAPIServerEndpointDiscovery",
+			"Effect":   "Allow",
+			"Action":   "eks:DescribeCluster",
+			"Resource": clusterArn,
+		},
+	})
+}
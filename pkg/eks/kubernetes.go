@@ -0,0 +1,84 @@
+package eks
+
+import (
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	"gopkg.in/yaml.v3"
+)
+
+// createKubernetesProvider creates a Kubernetes provider for the cluster. It
+// authenticates with the token of `aws eks get-token`, run with the profile and
+// the role of the AWS provider unless AwsAuth sets its own, so the AWS CLI has
+// to be installed where Pulumi runs.
+func (e *Eks) createKubernetesProvider(ctx *pulumi.Context, cluster *eks.Cluster) (*kubernetes.Provider, error) {
+	awsConfig := config.New(ctx, "aws")
+	profile := e.AwsAuth.Profile
+	if profile == "" {
+		profile = awsConfig.Get("profile")
+	}
+	roleArn := e.AwsAuth.RoleArn
+	if roleArn == "" {
+		var assumeRole struct {
+			RoleArn string `json:"roleArn"`
+		}
+		if err := awsConfig.TryObject("assumeRole", &assumeRole); err == nil {
+			roleArn = assumeRole.RoleArn
+		}
+	}
+
+	kubeconfig := pulumi.All(cluster.Name, cluster.Endpoint, cluster.CertificateAuthority.Data().Elem(), cluster.Arn).ApplyT(func(args []interface{}) (string, error) {
+		// arn:aws:eks:<region>:<account>:cluster/<name>
+		region := strings.Split(args[3].(string), ":")[3]
+		return renderKubeconfig(args[0].(string), args[1].(string), args[2].(string), region, profile, roleArn)
+	}).(pulumi.StringOutput)
+
+	return kubernetes.NewProvider(ctx, e.Name, &kubernetes.ProviderArgs{
+		Kubeconfig: kubeconfig,
+	})
+}
+
+// renderKubeconfig renders a kubeconfig for the cluster that gets its token
+// from `aws eks get-token`.
+func renderKubeconfig(name, endpoint, certificateAuthority, region, profile, roleArn string) (string, error) {
+	args := []string{"eks", "get-token", "--cluster-name", name, "--region", region}
+	if profile != "" {
+		args = append(args, "--profile", profile)
+	}
+	if roleArn != "" {
+		args = append(args, "--role-arn", roleArn)
+	}
+	config, err := yaml.Marshal(map[string]interface{}{
+		"apiVersion":      "v1",
+		"kind":            "Config",
+		"current-context": name,
+		"clusters": []interface{}{map[string]interface{}{
+			"name": name,
+			"cluster": map[string]interface{}{
+				"server":                     endpoint,
+				"certificate-authority-data": certificateAuthority,
+			},
+		}},
+		"contexts": []interface{}{map[string]interface{}{
+			"name": name,
+			"context": map[string]interface{}{
+				"cluster": name,
+				"user":    name,
+			},
+		}},
+		"users": []interface{}{map[string]interface{}{
+			"name": name,
+			"user": map[string]interface{}{
+				"exec": map[string]interface{}{
+					"apiVersion": "client.authentication.k8s.io/v1beta1",
+					"command":    "aws",
+					"args":       args,
+				},
+			},
+		}},
+	})
+	return string(config), err
+}
@@ -1,30 +1,45 @@
 package eks
 
 import (
-	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
-func (e *Eks) CreateLaunchTemplate(ctx *pulumi.Context, nodeSecurityGroupId pulumi.IDOutput) (*ec2.LaunchTemplate, error) {
+// CreateLaunchTemplate creates a launch template for the node security group.
+// The extra args set what LaunchTemplate can't hold, like the outputs of other
+// resources.
+func (e *Eks) CreateLaunchTemplate(ctx *pulumi.Context, name string, launchTemplate LaunchTemplate, nodeSecurityGroupId pulumi.IDOutput, extraArgs ...func(*ec2.LaunchTemplateArgs)) (*ec2.LaunchTemplate, error) {
+	templateArgs := launchTemplateArgs(launchTemplate, nodeSecurityGroupId)
+	for _, extraArg := range extraArgs {
+		extraArg(templateArgs)
+	}
+	template, err := ec2.NewLaunchTemplate(ctx, name, templateArgs)
+	if err != nil {
+		return &ec2.LaunchTemplate{}, err
+	}
+	return template, nil
+}
+
+func launchTemplateArgs(launchTemplate LaunchTemplate, nodeSecurityGroupId pulumi.IDOutput) *ec2.LaunchTemplateArgs {
 	launchTemplateArgs := &ec2.LaunchTemplateArgs{}
-	// launchTemplateArgs.CapacityReservationSpecification = e.ManagedNodeGroups.LaunchTemplate.CapacityReservation
-	launchTemplateArgs.VpcSecurityGroupIds = append(e.ManagedNodeGroups.LaunchTemplate.VpcSecurityGroupIds, nodeSecurityGroupId)
-	// launchTemplateArgs.CreditSpecification = e.ManagedNodeGroups.LaunchTemplate.CreditSpecification
-	// launchTemplateArgs.ElasticGpuSpecifications = e.ManagedNodeGroups.LaunchTemplate.ElasticGpuSpecifications
-	// launchTemplateArgs.InstanceMarketOptions = e.ManagedNodeGroups.LaunchTemplate.InstanceMarketOptions
-	// launchTemplateArgs.LicenseSpecifications = e.ManagedNodeGroups.LaunchTemplate.LicenseSpecifications
-	// launchTemplateArgs.MetadataOptions = e.ManagedNodeGroups.LaunchTemplate.MetadataOptions
-	// launchTemplateArgs.Monitoring = e.ManagedNodeGroups.LaunchTemplate.Monitoring
-
-	// launchTemplateArgs.Placement = e.ManagedNodeGroups.LaunchTemplate.Placement
-	// launchTemplateArgs.NetworkInterfaces = e.ManagedNodeGroups.LaunchTemplate.NetworkInterfaces
-	// launchTemplateArgs.TagSpecifications = e.ManagedNodeGroups.LaunchTemplate.TagSpecifications
-	if e.ManagedNodeGroups.LaunchTemplate.DiskSize > 0 {
+	// launchTemplateArgs.CapacityReservationSpecification = launchTemplate.CapacityReservation
+	launchTemplateArgs.VpcSecurityGroupIds = append(launchTemplate.VpcSecurityGroupIds, nodeSecurityGroupId)
+	// launchTemplateArgs.CreditSpecification = launchTemplate.CreditSpecification
+	// launchTemplateArgs.ElasticGpuSpecifications = launchTemplate.ElasticGpuSpecifications
+	// launchTemplateArgs.InstanceMarketOptions = launchTemplate.InstanceMarketOptions
+	// launchTemplateArgs.LicenseSpecifications = launchTemplate.LicenseSpecifications
+	// launchTemplateArgs.MetadataOptions = launchTemplate.MetadataOptions
+	// launchTemplateArgs.Monitoring = launchTemplate.Monitoring
+
+	// launchTemplateArgs.Placement = launchTemplate.Placement
+	// launchTemplateArgs.NetworkInterfaces = launchTemplate.NetworkInterfaces
+	// launchTemplateArgs.TagSpecifications = launchTemplate.TagSpecifications
+	if launchTemplate.DiskSize > 0 {
 		launchTemplateArgs.BlockDeviceMappings = ec2.LaunchTemplateBlockDeviceMappingArray{
 			&ec2.LaunchTemplateBlockDeviceMappingArgs{
 				DeviceName: pulumi.String("/dev/xvda"),
 				Ebs: &ec2.LaunchTemplateBlockDeviceMappingEbsArgs{
-					VolumeSize:          e.ManagedNodeGroups.LaunchTemplate.DiskSize,
+					VolumeSize:          launchTemplate.DiskSize,
 					VolumeType:          pulumi.String("gp3"),
 					Iops:                pulumi.Int(10000),
 					Throughput:          pulumi.Int(1000),
@@ -33,71 +48,67 @@ func (e *Eks) CreateLaunchTemplate(ctx *pulumi.Context, nodeSecurityGroupId pulu
 			},
 		}
 	}
-	if e.ManagedNodeGroups.LaunchTemplate.CpuCores > 0 {
+	if launchTemplate.CpuCores > 0 {
 		launchTemplateArgs.CpuOptions = &ec2.LaunchTemplateCpuOptionsArgs{
-			CoreCount: e.ManagedNodeGroups.LaunchTemplate.CpuCores,
+			CoreCount: launchTemplate.CpuCores,
 		}
 	}
 
-	if e.ManagedNodeGroups.LaunchTemplate.IamInstanceProfileName != "" {
+	if launchTemplate.IamInstanceProfileName != "" {
 		launchTemplateArgs.IamInstanceProfile = &ec2.LaunchTemplateIamInstanceProfileArgs{
-			Name: e.ManagedNodeGroups.LaunchTemplate.IamInstanceProfileName,
+			Name: launchTemplate.IamInstanceProfileName,
 		}
 	}
 
-	if e.ManagedNodeGroups.LaunchTemplate.ElasticInferenceAccelerator != "" {
+	if launchTemplate.ElasticInferenceAccelerator != "" {
 		launchTemplateArgs.ElasticInferenceAccelerator = &ec2.LaunchTemplateElasticInferenceAcceleratorArgs{
-			Type: e.ManagedNodeGroups.LaunchTemplate.ElasticInferenceAccelerator,
+			Type: launchTemplate.ElasticInferenceAccelerator,
 		}
 	}
 
-	if e.ManagedNodeGroups.LaunchTemplate.DisableApiStop {
-		launchTemplateArgs.DisableApiStop = e.ManagedNodeGroups.LaunchTemplate.DisableApiStop
+	if launchTemplate.DisableApiStop {
+		launchTemplateArgs.DisableApiStop = launchTemplate.DisableApiStop
 	}
 
-	if e.ManagedNodeGroups.LaunchTemplate.DisableApiTermination {
-		launchTemplateArgs.DisableApiTermination = e.ManagedNodeGroups.LaunchTemplate.DisableApiTermination
+	if launchTemplate.DisableApiTermination {
+		launchTemplateArgs.DisableApiTermination = launchTemplate.DisableApiTermination
 	}
 
-	if e.ManagedNodeGroups.LaunchTemplate.EbsOptimized != "" {
-		launchTemplateArgs.EbsOptimized = e.ManagedNodeGroups.LaunchTemplate.EbsOptimized
+	if launchTemplate.EbsOptimized != "" {
+		launchTemplateArgs.EbsOptimized = launchTemplate.EbsOptimized
 	}
 
-	if e.ManagedNodeGroups.LaunchTemplate.ImageId != "" {
-		launchTemplateArgs.ImageId = e.ManagedNodeGroups.LaunchTemplate.ImageId
+	if launchTemplate.ImageId != "" {
+		launchTemplateArgs.ImageId = launchTemplate.ImageId
 	}
 
-	if e.ManagedNodeGroups.LaunchTemplate.InstanceType != "" {
-		launchTemplateArgs.InstanceType = e.ManagedNodeGroups.LaunchTemplate.InstanceType
+	if launchTemplate.InstanceType != "" {
+		launchTemplateArgs.InstanceType = launchTemplate.InstanceType
 	}
 
-	if e.ManagedNodeGroups.LaunchTemplate.KernelId != "" {
-		launchTemplateArgs.KernelId = e.ManagedNodeGroups.LaunchTemplate.KernelId
+	if launchTemplate.KernelId != "" {
+		launchTemplateArgs.KernelId = launchTemplate.KernelId
 	}
 
-	if e.ManagedNodeGroups.LaunchTemplate.KeyName != "" {
-		launchTemplateArgs.KeyName = e.ManagedNodeGroups.LaunchTemplate.KeyName
+	if launchTemplate.KeyName != "" {
+		launchTemplateArgs.KeyName = launchTemplate.KeyName
 	}
 
-	if e.ManagedNodeGroups.LaunchTemplate.InstanceInitiatedShutdownBehavior != "" {
-		launchTemplateArgs.InstanceInitiatedShutdownBehavior = e.ManagedNodeGroups.LaunchTemplate.InstanceInitiatedShutdownBehavior
+	if launchTemplate.InstanceInitiatedShutdownBehavior != "" {
+		launchTemplateArgs.InstanceInitiatedShutdownBehavior = launchTemplate.InstanceInitiatedShutdownBehavior
 	}
 
-	if e.ManagedNodeGroups.LaunchTemplate.RamDiskId != "" {
-		launchTemplateArgs.RamDiskId = e.ManagedNodeGroups.LaunchTemplate.RamDiskId
+	if launchTemplate.RamDiskId != "" {
+		launchTemplateArgs.RamDiskId = launchTemplate.RamDiskId
 	}
 
-	if e.ManagedNodeGroups.LaunchTemplate.UserData != "" {
-		launchTemplateArgs.UserData = e.ManagedNodeGroups.LaunchTemplate.UserData
+	if launchTemplate.UserData != "" {
+		launchTemplateArgs.UserData = launchTemplate.UserData
 	}
 
-	if e.ManagedNodeGroups.LaunchTemplate.UpdateDefaultVersion {
+	if launchTemplate.UpdateDefaultVersion {
 		launchTemplateArgs.UpdateDefaultVersion = pulumi.Bool(true)
 	}
 	
-	template, err := ec2.NewLaunchTemplate(ctx, e.ManagedNodeGroups.Name, launchTemplateArgs)
-	if err != nil {
-		return &ec2.LaunchTemplate{}, err
-	}
-	return template, nil
+	return launchTemplateArgs
 }
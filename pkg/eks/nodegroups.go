@@ -2,16 +2,20 @@ package eks
 
 import (
 	"strconv"
+	"strings"
 
-	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ec2"
-	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/eks"
-	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
 	"github.com/pulumi/pulumi-tls/sdk/v4/go/tls"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
-func (e *Eks) CreateEksNodeGroups(ctx *pulumi.Context, nodeSecurityGroupId, clusterSecurityGroupId pulumi.IDOutput, clusterName pulumi.StringOutput) (*NodeGroupCreateOutPut, error) {
-	nodeGroupCreateOutput := &NodeGroupCreateOutPut{}
+// CreateEksNodeGroups creates the rules of the shared node security group and
+// one managed node group per entry of ManagedNodeGroups, all using the node
+// role given by nodeRoleArn.
+func (e *Eks) CreateEksNodeGroups(ctx *pulumi.Context, nodeSecurityGroupId, clusterSecurityGroupId pulumi.IDOutput, clusterName pulumi.StringOutput, nodeRoleArn pulumi.StringInput) (map[string]*NodeGroupCreateOutPut, error) {
+	nodeGroupsCreateOutput := map[string]*NodeGroupCreateOutPut{}
 
 	// Create Node SecurityGroupRule
 	nodeSecurityGroupRules := []securityGroupRule{
@@ -98,141 +102,204 @@ func (e *Eks) CreateEksNodeGroups(ctx *pulumi.Context, nodeSecurityGroupId, clus
 	}
 
 	nodeSecurityGroupRules = append(nodeSecurityGroupRules, e.NodeSecurityGroup.AdditionalRules...)
+	nodeSecurityGroupRules = append(nodeSecurityGroupRules, prefixListRules(e.NodeSecurityGroup.PrefixListRules)...)
 	for index, rule := range nodeSecurityGroupRules {
 		securityGroupRuleArgs := createSecurityGroupRule(rule)
 		securityGroupRuleArgs.SecurityGroupId = nodeSecurityGroupId
-		_, err := ec2.NewSecurityGroupRule(ctx, e.ManagedNodeGroups.Name+strconv.Itoa(index), securityGroupRuleArgs)
+		_, err := ec2.NewSecurityGroupRule(ctx, e.Name+"-node-"+strconv.Itoa(index), securityGroupRuleArgs, e.legacyNodeName(strconv.Itoa(index)))
 		if err != nil {
-			return nodeGroupCreateOutput, nil
+			return nodeGroupsCreateOutput, err
 		}
 	}
 
-	ngArgs := &eks.NodeGroupArgs{}
-	remoteAccess := &eks.NodeGroupRemoteAccessArgs{}
-
-	if e.ManagedNodeGroups.ExistingNodeRoleArn == "" {
-		nodeEksRole, err := iam.NewRole(ctx, e.ManagedNodeGroups.Name+"-node", &iam.RoleArgs{
-			AssumeRolePolicy: pulumi.String(`{
-				"Version": "2012-10-17",
-				"Statement": [{
-					"Sid": "",
-					"Effect": "Allow",
-					"Principal": {
-						"Service": "ec2.amazonaws.com"
-					},
-					"Action": "sts:AssumeRole"
-				}]
-			}`),
-		})
+	for _, key := range sortedKeys(e.ManagedNodeGroups) {
+		nodeGroupCreateOutput, err := e.createNodeGroup(ctx, key, e.ManagedNodeGroups[key], nodeSecurityGroupId, clusterName, nodeRoleArn)
 		if err != nil {
-			return nodeGroupCreateOutput, nil
+			return nodeGroupsCreateOutput, err
 		}
+		nodeGroupsCreateOutput[key] = nodeGroupCreateOutput
+	}
 
-		ngArgs.NodeRoleArn = nodeEksRole.Arn
-
-		nodeGroupPolicies := append([]string{
-			"arn:aws:iam::aws:policy/AmazonEKSWorkerNodePolicy",
-			"arn:aws:iam::aws:policy/AmazonEKS_CNI_Policy",
-			"arn:aws:iam::aws:policy/AmazonEC2ContainerRegistryReadOnly",
-		}, e.ManagedNodeGroups.IamRoleAdditionalPolicies...)
-
-		for index, nodeGroupPolicy := range nodeGroupPolicies {
-			_, err := iam.NewRolePolicyAttachment(ctx, e.ManagedNodeGroups.Name+strconv.Itoa(index), &iam.RolePolicyAttachmentArgs{
-				Role:      nodeEksRole.Name,
-				PolicyArn: pulumi.String(nodeGroupPolicy),
-			})
-			if err != nil {
-				return nodeGroupCreateOutput, nil
-			}
+	return nodeGroupsCreateOutput, nil
+}
+
+// createsNodeRole reports whether a node group uses the shared node role. Self-
+// managed node groups always do, managed node groups unless they set their own
+// ExistingNodeRoleArn.
+func (e *Eks) createsNodeRole() bool {
+	if len(e.ManagedNodeGroups) == 0 || len(e.SelfManagedNodeGroups) > 0 {
+		return true
+	}
+	for _, nodeGroup := range e.ManagedNodeGroups {
+		if nodeGroup.ExistingNodeRoleArn == "" {
+			return true
 		}
-	} else {
-		ngArgs.NodeRoleArn = e.ManagedNodeGroups.ExistingNodeRoleArn
 	}
+	return false
+}
+
+// createNodeRole creates the node IAM role shared by the node groups, unless an
+// existing role is given or no node group uses it. It attaches the additional
+// policies of the cluster and of every node group.
+func (e *Eks) createNodeRole(ctx *pulumi.Context) (pulumi.StringOutput, pulumi.StringOutput, error) {
+	if !e.createsNodeRole() {
+		return pulumi.StringOutput{}, pulumi.StringOutput{}, nil
+	}
+	if e.ExistingNodeRoleArn != "" {
+		roleName := e.ExistingNodeRoleArn.ToStringOutput().ApplyT(func(arn string) string {
+			return arn[strings.LastIndex(arn, "/")+1:]
+		}).(pulumi.StringOutput)
+		return e.ExistingNodeRoleArn.ToStringOutput(), roleName, nil
+	}
+
+	nodeEksRole, err := iam.NewRole(ctx, e.Name+"-node", &iam.RoleArgs{
+		AssumeRolePolicy: pulumi.String(`{
+			"Version": "2012-10-17",
+			"Statement": [{
+				"Sid": "",
+				"Effect": "Allow",
+				"Principal": {
+					"Service": "ec2.amazonaws.com"
+				},
+				"Action": "sts:AssumeRole"
+			}]
+		}`),
+		Tags: e.Tags,
+	}, e.legacyNodeName("-node"))
+	if err != nil {
+		return pulumi.StringOutput{}, pulumi.StringOutput{}, err
+	}
+
+	nodeGroupPolicies := append([]string{
+		"arn:aws:iam::aws:policy/AmazonEKSWorkerNodePolicy",
+		"arn:aws:iam::aws:policy/AmazonEKS_CNI_Policy",
+		"arn:aws:iam::aws:policy/AmazonEC2ContainerRegistryReadOnly",
+	}, e.NodeIamRoleAdditionalPolicies...)
+	for _, key := range sortedKeys(e.ManagedNodeGroups) {
+		nodeGroupPolicies = append(nodeGroupPolicies, e.ManagedNodeGroups[key].IamRoleAdditionalPolicies...)
+	}
+
+	attached := map[string]bool{}
+	for _, nodeGroupPolicy := range nodeGroupPolicies {
+		if attached[nodeGroupPolicy] {
+			continue
+		}
+		_, err := iam.NewRolePolicyAttachment(ctx, e.Name+"-node-"+strconv.Itoa(len(attached)), &iam.RolePolicyAttachmentArgs{
+			Role:      nodeEksRole.Name,
+			PolicyArn: pulumi.String(nodeGroupPolicy),
+		}, e.legacyNodeName(strconv.Itoa(len(attached))))
+		if err != nil {
+			return pulumi.StringOutput{}, pulumi.StringOutput{}, err
+		}
+		attached[nodeGroupPolicy] = true
+	}
+	return nodeEksRole.Arn, nodeEksRole.Name, nil
+}
 
-	if e.ManagedNodeGroups.EnableRemoteAccess {
+func (e *Eks) createNodeGroup(ctx *pulumi.Context, key string, nodeGroup NodeGroup, nodeSecurityGroupId pulumi.IDOutput, clusterName pulumi.StringOutput, nodeRoleArn pulumi.StringInput) (*NodeGroupCreateOutPut, error) {
+	nodeGroupCreateOutput := &NodeGroupCreateOutPut{}
+	name := nodeGroup.Name
+	if name == "" {
+		name = key
+	}
+
+	ngArgs := &eks.NodeGroupArgs{}
+	remoteAccess := &eks.NodeGroupRemoteAccessArgs{}
+
+	ngArgs.NodeRoleArn = nodeRoleArn
+	if nodeGroup.ExistingNodeRoleArn != "" {
+		ngArgs.NodeRoleArn = nodeGroup.ExistingNodeRoleArn
+	}
+
+	if nodeGroup.EnableRemoteAccess {
 		// Generate an AWS Key Pair for SSH access
-		privateKey, err := tls.NewPrivateKey(ctx, e.ManagedNodeGroups.Name, &tls.PrivateKeyArgs{
+		privateKey, err := tls.NewPrivateKey(ctx, name, &tls.PrivateKeyArgs{
 			Algorithm: pulumi.String("ED25519"),
 		})
 		if err != nil {
-			return nodeGroupCreateOutput, nil
+			return nodeGroupCreateOutput, err
+		}
+		ctx.Export(name+"-ssh", privateKey.PrivateKeyOpenssh)
+		if len(e.ManagedNodeGroups) == 1 {
+			// Stack output of the single node group before groups were keyed
+			ctx.Export("WorkerNodes-ssh", privateKey.PrivateKeyOpenssh)
 		}
-		ctx.Export("WorkerNodes-ssh", privateKey.PrivateKeyOpenssh)
 
-		keyPair, err := ec2.NewKeyPair(ctx, e.ManagedNodeGroups.Name, &ec2.KeyPairArgs{
-			KeyName:   pulumi.String(e.ManagedNodeGroups.Name),
+		keyPair, err := ec2.NewKeyPair(ctx, name, &ec2.KeyPairArgs{
+			KeyName:   pulumi.String(name),
 			PublicKey: privateKey.PublicKeyOpenssh,
 		})
 		if err != nil {
-			return nodeGroupCreateOutput, nil
+			return nodeGroupCreateOutput, err
 		}
 		remoteAccess.Ec2SshKey = keyPair.KeyName
 		// remoteAccess.SourceSecurityGroupIds     // TBD
 	}
 
-	if e.ManagedNodeGroups.AmiType != "" {
-		ngArgs.AmiType = e.ManagedNodeGroups.AmiType
+	if nodeGroup.AmiType != "" {
+		ngArgs.AmiType = nodeGroup.AmiType
 	}
-	if e.ManagedNodeGroups.CapacityType != "" {
-		ngArgs.CapacityType = e.ManagedNodeGroups.CapacityType
+	if nodeGroup.CapacityType != "" {
+		ngArgs.CapacityType = nodeGroup.CapacityType
 	}
 
-	if e.ManagedNodeGroups.DiskSize != 0 {
-		ngArgs.DiskSize = e.ManagedNodeGroups.DiskSize
+	if nodeGroup.DiskSize != 0 {
+		ngArgs.DiskSize = nodeGroup.DiskSize
 	}
 
-	if e.ManagedNodeGroups.ForceUpdateVersion {
-		ngArgs.ForceUpdateVersion = e.ManagedNodeGroups.ForceUpdateVersion
+	if nodeGroup.ForceUpdateVersion {
+		ngArgs.ForceUpdateVersion = nodeGroup.ForceUpdateVersion
 	}
 
-	if len(e.ManagedNodeGroups.InstanceTypes) < 1 {
-		ngArgs.InstanceTypes = e.ManagedNodeGroups.InstanceTypes
+	if len(nodeGroup.InstanceTypes) > 0 {
+		ngArgs.InstanceTypes = nodeGroup.InstanceTypes
 	}
 
-	if e.ManagedNodeGroups.AmiReleaseVersion != "" {
-		ngArgs.ReleaseVersion = e.ManagedNodeGroups.AmiReleaseVersion
+	if nodeGroup.AmiReleaseVersion != "" {
+		ngArgs.ReleaseVersion = nodeGroup.AmiReleaseVersion
 	}
 
-	if e.ManagedNodeGroups.ClusterVersion != "" {
-		ngArgs.Version = e.ManagedNodeGroups.ClusterVersion
+	if nodeGroup.ClusterVersion != "" {
+		ngArgs.Version = nodeGroup.ClusterVersion
 	}
 
-	ngArgs.Labels = e.ManagedNodeGroups.Labels
-	ngArgs.NodeGroupName = pulumi.String(e.ManagedNodeGroups.Name)
+	ngArgs.Labels = nodeGroup.Labels
+	ngArgs.NodeGroupName = pulumi.String(name)
 	ngArgs.ClusterName = clusterName
-	ngArgs.SubnetIds = e.ManagedNodeGroups.SubnetIds
-	ngArgs.Taints = e.ManagedNodeGroups.Taints
+	ngArgs.SubnetIds = nodeGroup.SubnetIds
+	ngArgs.Taints = nodeGroup.Taints
 	ngArgs.RemoteAccess = remoteAccess
 	ngArgs.Tags = e.Tags
 	ngArgs.ScalingConfig = &eks.NodeGroupScalingConfigArgs{
-		MaxSize:     e.ManagedNodeGroups.MaxSize,
-		MinSize:     e.ManagedNodeGroups.MinSize,
-		DesiredSize: e.ManagedNodeGroups.DesiredSize,
+		MaxSize:     nodeGroup.MaxSize,
+		MinSize:     nodeGroup.MinSize,
+		DesiredSize: nodeGroup.DesiredSize,
 	}
 
-	if e.ManagedNodeGroups.UseExistingLaunchTemplate {
+	if nodeGroup.UseExistingLaunchTemplate {
 		ngArgs.LaunchTemplate = &eks.NodeGroupLaunchTemplateArgs{
-			Id:      e.ManagedNodeGroups.ExistingLaunchTemplateId,
-			Version: e.ManagedNodeGroups.ExistingLaunchTemplateVersion,
+			Id:      nodeGroup.ExistingLaunchTemplateId,
+			Version: nodeGroup.ExistingLaunchTemplateVersion,
+		}
+	} else if nodeGroup.CreateLaunchTemplate {
+		template, err := e.CreateLaunchTemplate(ctx, name, nodeGroup.LaunchTemplate, nodeSecurityGroupId)
+		if err != nil {
+			return nodeGroupCreateOutput, err
 		}
-	} else if e.ManagedNodeGroups.CreateLaunchTemplate {
-		template, err := e.CreateLaunchTemplate(ctx, nodeSecurityGroupId)
 		ngArgs.LaunchTemplate = &eks.NodeGroupLaunchTemplateArgs{
 			Id:      template.ID(),
 			Version: pulumi.Sprintf("%d", template.LatestVersion.ToIntOutput()),
 		}
-		if err != nil {
-			return nodeGroupCreateOutput, err
-		}
+		nodeGroupCreateOutput.LaunchTemplateId = template.ID()
 	} else {
-		ngArgs.DiskSize = e.ManagedNodeGroups.DiskSize
+		ngArgs.DiskSize = nodeGroup.DiskSize
 	}
 	//  ###############  Create EKS Node Group  ###############
-	nodeGroup, err := eks.NewNodeGroup(ctx, e.ManagedNodeGroups.Name, ngArgs)
+	eksNodeGroup, err := eks.NewNodeGroup(ctx, name, ngArgs)
 	if err != nil {
-		return nodeGroupCreateOutput, nil
+		return nodeGroupCreateOutput, err
 	}
-	nodeGroupCreateOutput.NodeGroup = nodeGroup
+	nodeGroupCreateOutput.NodeGroup = eksNodeGroup
 
 	return nodeGroupCreateOutput, nil
 }
@@ -0,0 +1,34 @@
+package eks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// CreatePodIdentityAssociation lets the service account of the given namespace
+// assume roleArn through EKS Pod Identity. The role has to trust
+// pods.eks.amazonaws.com and the eks-pod-identity-agent addon has to run in
+// the cluster.
+func (o *EksCreateOutPut) CreatePodIdentityAssociation(ctx *pulumi.Context, name, namespace, serviceAccount string, roleArn pulumi.StringInput, tags pulumi.StringMap) (*eks.PodIdentityAssociation, error) {
+	if strings.Contains(namespace+serviceAccount, "*") {
+		return nil, fmt.Errorf("pod identity association %s: wildcards are not supported", name)
+	}
+	association, err := eks.NewPodIdentityAssociation(ctx, name, &eks.PodIdentityAssociationArgs{
+		ClusterName:    o.Cluster.Name,
+		Namespace:      pulumi.String(namespace),
+		ServiceAccount: pulumi.String(serviceAccount),
+		RoleArn:        roleArn,
+		Tags:           tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if o.PodIdentityAssociations == nil {
+		o.PodIdentityAssociations = map[string]*eks.PodIdentityAssociation{}
+	}
+	o.PodIdentityAssociations[name] = association
+	return association, nil
+}
@@ -0,0 +1,215 @@
+package eks
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/autoscaling"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ssm"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"gopkg.in/yaml.v3"
+)
+
+// CreateSelfManagedNodeGroups creates an Auto Scaling group per entry of
+// SelfManagedNodeGroups. The instances use the shared node role through an
+// instance profile and join the cluster with nodeadm on the AL2023 EKS
+// optimized AMI, or with the bootstrap script of the AL2 one. The node role has
+// to be mapped in aws-auth for the nodes to join: EKS maps it for managed node
+// groups, otherwise AwsAuth.Enabled has to be set.
+func (e *Eks) CreateSelfManagedNodeGroups(ctx *pulumi.Context, cluster *eks.Cluster, nodeSecurityGroupId pulumi.IDOutput, nodeRoleName pulumi.StringOutput) (map[string]*SelfManagedNodeGroupCreateOutPut, error) {
+	selfManagedNodeGroupsCreateOutput := map[string]*SelfManagedNodeGroupCreateOutPut{}
+	if len(e.SelfManagedNodeGroups) == 0 {
+		return selfManagedNodeGroupsCreateOutput, nil
+	}
+	if len(e.ManagedNodeGroups) == 0 && !e.AwsAuth.Enabled {
+		return selfManagedNodeGroupsCreateOutput, fmt.Errorf("self-managed node groups of %s need AwsAuth.Enabled or a managed node group to map the node role", e.Name)
+	}
+
+	instanceProfile, err := iam.NewInstanceProfile(ctx, e.Name+"-node", &iam.InstanceProfileArgs{
+		Role: nodeRoleName,
+		Tags: e.Tags,
+	})
+	if err != nil {
+		return selfManagedNodeGroupsCreateOutput, err
+	}
+
+	for _, key := range sortedKeys(e.SelfManagedNodeGroups) {
+		nodeGroup := e.SelfManagedNodeGroups[key]
+		name := nodeGroup.Name
+		if name == "" {
+			name = key
+		}
+
+		// Launch template with the EKS optimized AMI and the user data joining
+		// the nodes to the cluster
+		amiFamily := nodeGroup.AmiFamily
+		if amiFamily == "" {
+			amiFamily = "AL2023"
+		}
+		if amiFamily != "AL2023" && amiFamily != "AL2" {
+			return selfManagedNodeGroupsCreateOutput, fmt.Errorf("self-managed node group %s: unknown AmiFamily %q", name, amiFamily)
+		}
+		template, err := e.CreateLaunchTemplate(ctx, e.Name+"-"+name, nodeGroup.LaunchTemplate, nodeSecurityGroupId, func(templateArgs *ec2.LaunchTemplateArgs) {
+			templateArgs.IamInstanceProfile = &ec2.LaunchTemplateIamInstanceProfileArgs{
+				Arn: instanceProfile.Arn,
+			}
+			if nodeGroup.LaunchTemplate.ImageId == "" {
+				amiParameter := nodeGroup.AmiSsmParameter
+				if amiParameter == nil {
+					amiParameter = cluster.Version.ApplyT(func(version string) string {
+						return amiSsmParameter(amiFamily, version)
+					}).(pulumi.StringOutput)
+				}
+				templateArgs.ImageId = ssm.LookupParameterOutput(ctx, ssm.LookupParameterOutputArgs{
+					Name: amiParameter,
+				}).Value()
+			}
+			if nodeGroup.LaunchTemplate.UserData == "" {
+				templateArgs.UserData = pulumi.All(cluster.Name, cluster.Endpoint, cluster.CertificateAuthority.Data().Elem(), cluster.KubernetesNetworkConfig.ServiceIpv4Cidr().Elem(), cluster.Version).ApplyT(func(args []interface{}) (string, error) {
+					if amiFamily == "AL2" {
+						return bootstrapUserData(args[0].(string), args[1].(string), args[2].(string), args[4].(string), nodeGroup.BootstrapExtraArgs)
+					}
+					return nodeadmUserData(args[0].(string), args[1].(string), args[2].(string), args[3].(string), nodeGroup.KubeletFlags)
+				}).(pulumi.StringOutput)
+			}
+		})
+		if err != nil {
+			return selfManagedNodeGroupsCreateOutput, err
+		}
+
+		// Mixed instances policy
+		overrides := autoscaling.GroupMixedInstancesPolicyLaunchTemplateOverrideArray{}
+		for _, instanceType := range nodeGroup.InstanceTypes {
+			overrides = append(overrides, autoscaling.GroupMixedInstancesPolicyLaunchTemplateOverrideArgs{
+				InstanceType: pulumi.String(instanceType),
+			})
+		}
+		instancesDistribution := &autoscaling.GroupMixedInstancesPolicyInstancesDistributionArgs{
+			OnDemandBaseCapacity:                nodeGroup.OnDemandBaseCapacity,
+			OnDemandPercentageAboveBaseCapacity: pulumi.Int(100),
+		}
+		if nodeGroup.OnDemandPercentageAboveBaseCapacity != nil {
+			instancesDistribution.OnDemandPercentageAboveBaseCapacity = nodeGroup.OnDemandPercentageAboveBaseCapacity
+		}
+		if nodeGroup.SpotAllocationStrategy != "" {
+			instancesDistribution.SpotAllocationStrategy = nodeGroup.SpotAllocationStrategy
+		}
+
+		// Instance refresh, rolling on launch template changes by default
+		instanceRefresh := nodeGroup.InstanceRefresh
+		if instanceRefresh == nil {
+			instanceRefresh = &autoscaling.GroupInstanceRefreshArgs{
+				Strategy: pulumi.String("Rolling"),
+				Preferences: &autoscaling.GroupInstanceRefreshPreferencesArgs{
+					MinHealthyPercentage: pulumi.Int(66),
+				},
+			}
+		}
+
+		tags := autoscaling.GroupTagArray{
+			autoscaling.GroupTagArgs{
+				Key:               pulumi.String("Name"),
+				Value:             pulumi.String(e.Name + "-" + name),
+				PropagateAtLaunch: pulumi.Bool(true),
+			},
+			autoscaling.GroupTagArgs{
+				Key:               pulumi.Sprintf("kubernetes.io/cluster/%s", cluster.Name),
+				Value:             pulumi.String("owned"),
+				PropagateAtLaunch: pulumi.Bool(true),
+			},
+		}
+		groupTags := mergeTags(e.Tags, nodeGroup.Tags)
+		for _, tagKey := range sortedKeys(groupTags) {
+			tags = append(tags, autoscaling.GroupTagArgs{
+				Key:               pulumi.String(tagKey),
+				Value:             groupTags[tagKey],
+				PropagateAtLaunch: pulumi.Bool(true),
+			})
+		}
+
+		group, err := autoscaling.NewGroup(ctx, e.Name+"-"+name, &autoscaling.GroupArgs{
+			MinSize:               nodeGroup.MinSize,
+			MaxSize:               nodeGroup.MaxSize,
+			DesiredCapacity:       nodeGroup.DesiredSize,
+			VpcZoneIdentifiers:    nodeGroup.SubnetIds,
+			CapacityRebalance:     nodeGroup.CapacityRebalance,
+			InitialLifecycleHooks: nodeGroup.LifecycleHooks,
+			InstanceRefresh:       instanceRefresh,
+			WarmPool:              nodeGroup.WarmPool,
+			Tags:                  tags,
+			MixedInstancesPolicy: &autoscaling.GroupMixedInstancesPolicyArgs{
+				InstancesDistribution: instancesDistribution,
+				LaunchTemplate: &autoscaling.GroupMixedInstancesPolicyLaunchTemplateArgs{
+					LaunchTemplateSpecification: &autoscaling.GroupMixedInstancesPolicyLaunchTemplateLaunchTemplateSpecificationArgs{
+						LaunchTemplateId: template.ID(),
+						Version:          pulumi.Sprintf("%d", template.LatestVersion),
+					},
+					Overrides: overrides,
+				},
+			},
+		}, pulumi.DependsOn([]pulumi.Resource{cluster}))
+		if err != nil {
+			return selfManagedNodeGroupsCreateOutput, err
+		}
+
+		selfManagedNodeGroupsCreateOutput[key] = &SelfManagedNodeGroupCreateOutPut{
+			AutoscalingGroup:   group,
+			InstanceProfileArn: instanceProfile.Arn,
+			LaunchTemplateId:   template.ID(),
+		}
+	}
+
+	return selfManagedNodeGroupsCreateOutput, nil
+}
+
+// amiSsmParameter returns the SSM parameter holding the EKS optimized AMI of
+// the given family for a Kubernetes version.
+func amiSsmParameter(amiFamily, version string) string {
+	if amiFamily == "AL2" {
+		return fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2/recommended/image_id", version)
+	}
+	return fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2023/x86_64/standard/recommended/image_id", version)
+}
+
+// nodeadmUserData returns the user data of an AL2023 node: a nodeadm NodeConfig
+// in a MIME multi-part document.
+func nodeadmUserData(clusterName, endpoint, certificateAuthority, serviceCidr string, kubeletFlags []string) (string, error) {
+	nodeConfig := map[string]interface{}{
+		"apiVersion": "node.eks.aws/v1alpha1",
+		"kind":       "NodeConfig",
+		"spec": map[string]interface{}{
+			"cluster": map[string]interface{}{
+				"name":                 clusterName,
+				"apiServerEndpoint":    endpoint,
+				"certificateAuthority": certificateAuthority,
+				"cidr":                 serviceCidr,
+			},
+		},
+	}
+	if len(kubeletFlags) > 0 {
+		nodeConfig["spec"].(map[string]interface{})["kubelet"] = map[string]interface{}{"flags": kubeletFlags}
+	}
+	rendered, err := yaml.Marshal(nodeConfig)
+	if err != nil {
+		return "", err
+	}
+	userData := "MIME-Version: 1.0\nContent-Type: multipart/mixed; boundary=\"//\"\n\n" +
+		"--//\nContent-Type: application/node.eks.aws\n\n---\n" + string(rendered) + "\n--//--\n"
+	return base64.StdEncoding.EncodeToString([]byte(userData)), nil
+}
+
+// bootstrapUserData returns the user data of an AL2 node, which runs the
+// bootstrap script of the AMI. EKS stopped publishing AL2 AMIs with Kubernetes
+// 1.33.
+func bootstrapUserData(clusterName, endpoint, certificateAuthority, version, extraArgs string) (string, error) {
+	var major, minor int
+	if _, err := fmt.Sscanf(version, "%d.%d", &major, &minor); err == nil && (major > 1 || minor >= 33) {
+		return "", fmt.Errorf("cluster %s: there are no AL2 AMIs for Kubernetes %s, use the AL2023 AmiFamily", clusterName, version)
+	}
+	userData := fmt.Sprintf("#!/bin/bash\nset -o xtrace\n/etc/eks/bootstrap.sh %s --apiserver-endpoint %s --b64-cluster-ca %s %s\n",
+		clusterName, endpoint, certificateAuthority, extraArgs)
+	return base64.StdEncoding.EncodeToString([]byte(userData)), nil
+}
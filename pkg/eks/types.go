@@ -1,14 +1,17 @@
 package eks
 
 import (
-	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ec2"
-	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/eks"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/autoscaling"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
 type Eks struct {
+	AccessConfig                          AccessConfig
 	AdditionalSecurityGroupIds            pulumi.StringArray
 	AttachClusterEncryptionPolicy         pulumi.Bool
+	AwsAuth                               AwsAuth
 	CloudWatchLogGroup                    cloudWatchLogGroup
 	ClusterAddons                         []Addon
 	ClusterEncryptionConfig               pulumi.StringArrayMap
@@ -23,15 +26,24 @@ type Eks struct {
 	CreateClusterPrimarySecurityGroupTags pulumi.Bool
 	EnabledLogTypes                       pulumi.StringArray
 	EncryptionKey                         Kms
+	ExistingNodeRoleArn                   pulumi.String
+	FargateRoleAdditionalPolicies         []string
+	FargateProfiles                       map[string]FargateProfile
 	IamRoleAdditionalPolicieArns          []string
-	IdentityProvider                      identityProvider
-	Irsa                                  irsa
-	Name                                  string
-	NodeSecurityGroup                     securityGroup
-	ManagedNodeGroups                     NodeGroup
-	SubnetIds                             pulumi.StringArray
-	Tags                                  pulumi.StringMap
-	Version                               pulumi.String
+	IdentityProvider                      *IdentityProvider
+	// Deprecated: use IdentityProvider, EKS allows a single one per cluster.
+	IdentityProviders             map[string]IdentityProvider
+	Irsa                          irsa
+	Karpenter                     Karpenter
+	Name                          string
+	NodeIamRoleAdditionalPolicies []string
+	NodeSecurityGroup             securityGroup
+	ManagedNodeGroups             map[string]NodeGroup
+	SelfManagedNodeGroups         map[string]SelfManagedNodeGroup
+	SkipLegacyAliases             bool
+	SubnetIds                     pulumi.StringArray
+	Tags                          pulumi.StringMap
+	Version                       pulumi.String
 }
 
 type Kms struct {
@@ -62,6 +74,7 @@ type securityGroup struct {
 	EnableDefaultRules      bool
 	ExistingSecurityGroupId pulumi.String
 	Name                    string
+	PrefixListRules         []PrefixListRule
 	Tags                    pulumi.StringMap
 	VpcId                   pulumi.StringInput
 }
@@ -72,15 +85,29 @@ type securityGroupRule struct {
 	fromPort              int
 	ipv6CidrBlocks        string
 	kind                  string
+	prefixListIds         pulumi.StringArray
 	protocol              string
 	self                  bool
 	sourceSecurityGroupId pulumi.IDOutput
 	toPort                int
 }
 
+type PrefixListRule struct {
+	Description   string
+	FromPort      int
+	Kind          string
+	PrefixListIds pulumi.StringArray
+	Protocol      string
+	ToPort        int
+}
+
 type irsa struct {
-	CustomOidcThumbprints   pulumi.StringArray
-	Enabled                 bool
+	CustomOidcThumbprints pulumi.StringArray
+	Disabled              bool
+	// Deprecated: IRSA is created unless Disabled is set. Setting Enabled to
+	// false is an error, as it no longer turns IRSA off.
+	Enabled                 *bool
+	ExistingOidcProviderArn pulumi.String
 	OpendIdConnectAudiences pulumi.StringArray
 }
 
@@ -93,8 +120,16 @@ type Addon struct {
 	ConfigurationValues   pulumi.String
 }
 
-type identityProvider struct {
-	Name pulumi.StringMap
+type IdentityProvider struct {
+	ClientId       pulumi.String
+	GroupsClaim    pulumi.String
+	GroupsPrefix   pulumi.String
+	IssuerUrl      pulumi.String
+	Name           string
+	RequiredClaims pulumi.StringMap
+	Tags           pulumi.StringMap
+	UsernameClaim  pulumi.String
+	UsernamePrefix pulumi.String
 }
 
 type NodeGroup struct {
@@ -158,6 +193,105 @@ type NodeGroup struct {
 	UseExistingLaunchTemplate          bool
 }
 
+type AccessConfig struct {
+	AuthenticationMode        pulumi.String
+	Entries                   map[string]AccessEntry
+	SkipCreatorAdminBootstrap bool
+}
+
+type AccessEntry struct {
+	KubernetesGroups pulumi.StringArray
+	Policies         []AccessPolicy
+	PrincipalArn     pulumi.StringInput
+	Tags             pulumi.StringMap
+	Type             pulumi.String
+	Username         pulumi.String
+}
+
+type AccessPolicy struct {
+	Namespaces pulumi.StringArray
+	Policy     string
+}
+
+type AwsAuth struct {
+	Enabled     bool
+	MapAccounts []string
+	MapRoles    []AwsAuthRole
+	MapUsers    []AwsAuthUser
+	// Profile and RoleArn are passed to `aws eks get-token`. They default to
+	// the profile and the assumed role of the AWS provider.
+	Profile string
+	RoleArn string
+}
+
+type AwsAuthRole struct {
+	Groups   []string `yaml:"groups"`
+	RoleArn  string   `yaml:"rolearn"`
+	Username string   `yaml:"username"`
+}
+
+type AwsAuthUser struct {
+	Groups   []string `yaml:"groups"`
+	UserArn  string   `yaml:"userarn"`
+	Username string   `yaml:"username"`
+}
+
+type FargateProfile struct {
+	Name      string
+	Selectors []FargateProfileSelector
+	SubnetIds pulumi.StringArray
+	Tags      pulumi.StringMap
+}
+
+type FargateProfileSelector struct {
+	Labels    pulumi.StringMap
+	Namespace string
+}
+
+type Karpenter struct {
+	Enabled                    bool
+	Namespace                  string
+	NodeRoleAdditionalPolicies []string
+	// PodIdentity makes the controller role trust EKS Pod Identity and
+	// associates it with the service account.
+	PodIdentity    bool
+	ServiceAccount string
+}
+
+type ServiceAccountRole struct {
+	Description         pulumi.String
+	InlinePolicies      map[string]pulumi.StringInput
+	Namespace           string
+	PermissionsBoundary pulumi.String
+	PodIdentity         bool
+	PolicyArns          []string
+	ServiceAccount      string
+	Tags                pulumi.StringMap
+}
+
+type SelfManagedNodeGroup struct {
+	// AmiFamily is AL2023, the default, or AL2.
+	AmiFamily                           string
+	AmiSsmParameter                     pulumi.StringInput
+	BootstrapExtraArgs                  string
+	CapacityRebalance                   pulumi.Bool
+	DesiredSize                         pulumi.Int
+	InstanceRefresh                     *autoscaling.GroupInstanceRefreshArgs
+	InstanceTypes                       []string
+	KubeletFlags                        []string
+	LaunchTemplate                      LaunchTemplate
+	LifecycleHooks                      autoscaling.GroupInitialLifecycleHookArray
+	MaxSize                             pulumi.Int
+	MinSize                             pulumi.Int
+	Name                                string
+	OnDemandBaseCapacity                pulumi.Int
+	OnDemandPercentageAboveBaseCapacity pulumi.IntPtrInput
+	SpotAllocationStrategy              pulumi.String
+	SubnetIds                           pulumi.StringArray
+	Tags                                pulumi.StringMap
+	WarmPool                            *autoscaling.GroupWarmPoolArgs
+}
+
 type LaunchTemplate struct {
 	BlockDeviceMappings               ec2.LaunchTemplateBlockDeviceMappingArray
 	CapacityReservation               ec2.LaunchTemplateCapacityReservationSpecificationArgs
@@ -189,10 +323,46 @@ type LaunchTemplate struct {
 }
 
 type NodeGroupCreateOutPut struct {
-	NodeGroup *eks.NodeGroup
+	LaunchTemplateId pulumi.IDOutput
+	NodeGroup        *eks.NodeGroup
+}
+
+type SelfManagedNodeGroupCreateOutPut struct {
+	AutoscalingGroup   *autoscaling.Group
+	InstanceProfileArn pulumi.StringOutput
+	LaunchTemplateId   pulumi.IDOutput
+}
+
+type FargateCreateOutPut struct {
+	PodExecutionRoleArn pulumi.StringOutput
+	Profiles            map[string]*eks.FargateProfile
+}
+
+type KarpenterCreateOutPut struct {
+	ControllerRoleArn      pulumi.StringOutput
+	InstanceProfileName    pulumi.StringOutput
+	NodeAccessEntry        *eks.AccessEntry
+	NodeRoleArn            pulumi.StringOutput
+	NodeRoleName           pulumi.StringOutput
+	PodIdentityAssociation *eks.PodIdentityAssociation
+	QueueName              pulumi.StringOutput
 }
 
 type EksCreateOutPut struct {
-	Cluster *eks.Cluster
-	NodeGroupOutput *NodeGroupCreateOutPut
+	AccessEntries    map[string]*eks.AccessEntry
+	AwsAuthData      pulumi.StringMapOutput
+	Cluster          *eks.Cluster
+	Fargate          *FargateCreateOutPut
+	IdentityProvider *eks.IdentityProviderConfig
+	// Deprecated: use IdentityProvider.
+	IdentityProviders       map[string]*eks.IdentityProviderConfig
+	Karpenter               *KarpenterCreateOutPut
+	NodeGroups              map[string]*NodeGroupCreateOutPut
+	NodeRoleArn             pulumi.StringOutput
+	NodeRoleName            pulumi.StringOutput
+	NodeSecurityGroupId     pulumi.IDOutput
+	OidcProviderArn         pulumi.StringOutput
+	OidcProviderUrl         pulumi.StringOutput
+	PodIdentityAssociations map[string]*eks.PodIdentityAssociation
+	SelfManagedNodeGroups   map[string]*SelfManagedNodeGroupCreateOutPut
 }
@@ -1,7 +1,12 @@
 package eks
 
 import (
-	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ec2"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
@@ -20,6 +25,8 @@ func createSecurityGroupRule(securityGroupRule securityGroupRule) *ec2.SecurityG
 		}
 	} else if securityGroupRule.self {
 		securityGroupRuleArgs.Self = pulumi.BoolPtr(securityGroupRule.self)
+	} else if len(securityGroupRule.prefixListIds) > 0 {
+		securityGroupRuleArgs.PrefixListIds = securityGroupRule.prefixListIds
 	} else {
 		securityGroupRuleArgs.SourceSecurityGroupId = securityGroupRule.sourceSecurityGroupId
 	}
@@ -32,6 +39,25 @@ func createSecurityGroupRule(securityGroupRule securityGroupRule) *ec2.SecurityG
 	return securityGroupRuleArgs
 }
 
+func prefixListRules(rules []PrefixListRule) []securityGroupRule {
+	securityGroupRules := []securityGroupRule{}
+	for _, rule := range rules {
+		kind := rule.Kind
+		if kind == "" {
+			kind = "ingress"
+		}
+		securityGroupRules = append(securityGroupRules, securityGroupRule{
+			kind:          kind,
+			fromPort:      rule.FromPort,
+			toPort:        rule.ToPort,
+			protocol:      rule.Protocol,
+			description:   rule.Description,
+			prefixListIds: rule.PrefixListIds,
+		})
+	}
+	return securityGroupRules
+}
+
 func mergeTags(tags ...pulumi.StringMap) pulumi.StringMap {
 	merged := make(pulumi.StringMap)
 	for _, tag := range tags {
@@ -41,3 +67,95 @@ func mergeTags(tags ...pulumi.StringMap) pulumi.StringMap {
 	}
 	return merged
 }
+
+// legacyNodeName aliases a shared node resource to the name it had when
+// ManagedNodeGroups held a single group and the resource was named after it.
+// There is an alias per group, as that group may have been joined by others.
+func (e *Eks) legacyNodeName(suffix string) pulumi.ResourceOption {
+	if e.SkipLegacyAliases {
+		return pulumi.Aliases(nil)
+	}
+	aliases := []pulumi.Alias{}
+	for _, key := range sortedKeys(e.ManagedNodeGroups) {
+		name := e.ManagedNodeGroups[key].Name
+		if name == "" {
+			name = key
+		}
+		aliases = append(aliases, pulumi.Alias{Name: pulumi.String(name + suffix)})
+	}
+	return pulumi.Aliases(aliases)
+}
+
+// sortedKeys returns the keys of a map in a stable order.
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// podIdentityStatement lets EKS Pod Identity assume a role.
+var podIdentityStatement = map[string]interface{}{
+	"Effect":    "Allow",
+	"Principal": map[string]string{"Service": "pods.eks.amazonaws.com"},
+	"Action":    []string{"sts:AssumeRole", "sts:TagSession"},
+}
+
+// serviceAccountStatement lets a Kubernetes service account assume a role
+// through the cluster OIDC provider. A service account with a wildcard matches
+// the subject with StringLike.
+func serviceAccountStatement(oidcProviderArn, issuer, namespace, serviceAccount string) map[string]interface{} {
+	subject := fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccount)
+	condition := map[string]interface{}{
+		"StringEquals": map[string]string{
+			issuer + ":sub": subject,
+			issuer + ":aud": "sts.amazonaws.com",
+		},
+	}
+	if strings.Contains(subject, "*") {
+		condition = map[string]interface{}{
+			"StringEquals": map[string]string{issuer + ":aud": "sts.amazonaws.com"},
+			"StringLike":   map[string]string{issuer + ":sub": subject},
+		}
+	}
+	return map[string]interface{}{
+		"Effect":    "Allow",
+		"Principal": map[string]string{"Federated": oidcProviderArn},
+		"Action":    "sts:AssumeRoleWithWebIdentity",
+		"Condition": condition,
+	}
+}
+
+// serviceAccountAssumeRolePolicy returns the IRSA trust policy of a service
+// account role. With podIdentity the role also trusts EKS Pod Identity, so
+// workloads can move from IRSA to Pod Identity without a new role.
+func serviceAccountAssumeRolePolicy(oidcProviderArn, oidcProviderUrl pulumi.StringOutput, namespace, serviceAccount string, podIdentity bool) pulumi.StringOutput {
+	return pulumi.All(oidcProviderArn, oidcProviderUrl).ApplyT(func(args []interface{}) (string, error) {
+		statements := []map[string]interface{}{
+			serviceAccountStatement(args[0].(string), args[1].(string), namespace, serviceAccount),
+		}
+		if podIdentity {
+			statements = append(statements, podIdentityStatement)
+		}
+		return marshalPolicy(statements)
+	}).(pulumi.StringOutput)
+}
+
+// podIdentityAssumeRolePolicy returns the trust policy of a role used only
+// through EKS Pod Identity.
+func podIdentityAssumeRolePolicy() (string, error) {
+	return marshalPolicy([]map[string]interface{}{podIdentityStatement})
+}
+
+func marshalPolicy(statements []map[string]interface{}) (string, error) {
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version":   "2012-10-17",
+		"Statement": statements,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(policy), nil
+}
@@ -0,0 +1,235 @@
+package network
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/echibuogwu/pulumi-aws-go/pkg/vpc"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2transitgateway"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// CreateNetwork builds an egress hub VPC and its spoke VPCs joined by a transit
+// gateway. Spokes have no NAT gateways of their own: their default route goes
+// to the hub, whose NAT gateways reach the internet. The spokes listed in
+// AllowedSpokes share a transit gateway route table routing between them, so
+// they reach each other both ways. Traffic to any other spoke is dropped.
+func (n *Network) CreateNetwork(ctx *pulumi.Context) (*NetworkCreateOutput, error) {
+	networkCreateOutput := &NetworkCreateOutput{
+		Spokes:           map[string]*vpc.VpcCreateOutput{},
+		VpcAttachmentIds: pulumi.StringMap{},
+	}
+
+	spokeNames := []string{}
+	for _, spoke := range n.Spokes {
+		spokeNames = append(spokeNames, spoke.Name)
+	}
+	for _, allowed := range n.AllowedSpokes {
+		if !contains(spokeNames, allowed) {
+			return networkCreateOutput, fmt.Errorf("allowed spoke %s is not a spoke of %s", allowed, n.Name)
+		}
+	}
+
+	// Create hub and spoke VPCs
+	hubOutput, err := n.Hub.CreateVpc(ctx)
+	if err != nil {
+		return networkCreateOutput, err
+	}
+	networkCreateOutput.Hub = hubOutput
+
+	for index := range n.Spokes {
+		spoke := &n.Spokes[index]
+		spoke.NatGateway.Mode = "none"
+		spokeOutput, err := spoke.CreateVpc(ctx)
+		if err != nil {
+			return networkCreateOutput, err
+		}
+		networkCreateOutput.Spokes[spoke.Name] = spokeOutput
+	}
+
+	// Create Transit Gateway
+	transitGateway, err := ec2transitgateway.NewTransitGateway(ctx, n.Name, &ec2transitgateway.TransitGatewayArgs{
+		AmazonSideAsn:                n.TransitGateway.AmazonSideAsn,
+		Description:                  n.TransitGateway.Description,
+		DefaultRouteTableAssociation: pulumi.String("disable"),
+		DefaultRouteTablePropagation: pulumi.String("disable"),
+		Tags:                         mergeTags(pulumi.StringMap{"Name": pulumi.String(n.Name)}, n.Tags),
+	})
+	if err != nil {
+		return networkCreateOutput, err
+	}
+	networkCreateOutput.TransitGatewayId = transitGateway.ID()
+
+	hubRouteTable, err := ec2transitgateway.NewRouteTable(ctx, n.Name+"-hub", &ec2transitgateway.RouteTableArgs{
+		TransitGatewayId: transitGateway.ID(),
+		Tags:             mergeTags(pulumi.StringMap{"Name": pulumi.String(n.Name + "-hub")}, n.Tags),
+	})
+	if err != nil {
+		return networkCreateOutput, err
+	}
+	networkCreateOutput.HubRouteTableId = hubRouteTable.ID()
+
+	spokeRouteTable, err := ec2transitgateway.NewRouteTable(ctx, n.Name+"-spoke", &ec2transitgateway.RouteTableArgs{
+		TransitGatewayId: transitGateway.ID(),
+		Tags:             mergeTags(pulumi.StringMap{"Name": pulumi.String(n.Name + "-spoke")}, n.Tags),
+	})
+	if err != nil {
+		return networkCreateOutput, err
+	}
+	networkCreateOutput.SpokeRouteTableId = spokeRouteTable.ID()
+
+	var allowedRouteTable *ec2transitgateway.RouteTable
+	if len(n.AllowedSpokes) > 0 {
+		allowedRouteTable, err = ec2transitgateway.NewRouteTable(ctx, n.Name+"-allowed", &ec2transitgateway.RouteTableArgs{
+			TransitGatewayId: transitGateway.ID(),
+			Tags:             mergeTags(pulumi.StringMap{"Name": pulumi.String(n.Name + "-allowed")}, n.Tags),
+		})
+		if err != nil {
+			return networkCreateOutput, err
+		}
+		networkCreateOutput.AllowedRouteTableId = allowedRouteTable.ID()
+	}
+
+	// Hub attachment
+	hubAttachment, err := ec2transitgateway.NewVpcAttachment(ctx, n.Hub.Name, &ec2transitgateway.VpcAttachmentArgs{
+		TransitGatewayId: transitGateway.ID(),
+		VpcId:            hubOutput.VpcId,
+		SubnetIds:        hubOutput.PrivateSubnetsIds,
+		TransitGatewayDefaultRouteTableAssociation: pulumi.Bool(false),
+		TransitGatewayDefaultRouteTablePropagation: pulumi.Bool(false),
+		Tags: mergeTags(pulumi.StringMap{"Name": pulumi.String(n.Hub.Name)}, n.Tags),
+	})
+	if err != nil {
+		return networkCreateOutput, err
+	}
+	networkCreateOutput.VpcAttachmentIds[n.Hub.Name] = hubAttachment.ID()
+
+	_, err = ec2transitgateway.NewRouteTableAssociation(ctx, n.Hub.Name, &ec2transitgateway.RouteTableAssociationArgs{
+		TransitGatewayAttachmentId: hubAttachment.ID(),
+		TransitGatewayRouteTableId: hubRouteTable.ID(),
+	})
+	if err != nil {
+		return networkCreateOutput, err
+	}
+
+	// Spokes send everything that is not local to the hub
+	_, err = ec2transitgateway.NewRoute(ctx, n.Name+"-spoke-default", &ec2transitgateway.RouteArgs{
+		DestinationCidrBlock:       pulumi.String("0.0.0.0/0"),
+		TransitGatewayAttachmentId: hubAttachment.ID(),
+		TransitGatewayRouteTableId: spokeRouteTable.ID(),
+	})
+	if err != nil {
+		return networkCreateOutput, err
+	}
+	if allowedRouteTable != nil {
+		_, err = ec2transitgateway.NewRoute(ctx, n.Name+"-allowed-default", &ec2transitgateway.RouteArgs{
+			DestinationCidrBlock:       pulumi.String("0.0.0.0/0"),
+			TransitGatewayAttachmentId: hubAttachment.ID(),
+			TransitGatewayRouteTableId: allowedRouteTable.ID(),
+		})
+		if err != nil {
+			return networkCreateOutput, err
+		}
+	}
+
+	for _, spoke := range n.Spokes {
+		spokeOutput := networkCreateOutput.Spokes[spoke.Name]
+
+		spokeAttachment, err := ec2transitgateway.NewVpcAttachment(ctx, spoke.Name, &ec2transitgateway.VpcAttachmentArgs{
+			TransitGatewayId: transitGateway.ID(),
+			VpcId:            spokeOutput.VpcId,
+			SubnetIds:        spokeOutput.PrivateSubnetsIds,
+			TransitGatewayDefaultRouteTableAssociation: pulumi.Bool(false),
+			TransitGatewayDefaultRouteTablePropagation: pulumi.Bool(false),
+			Tags: mergeTags(pulumi.StringMap{"Name": pulumi.String(spoke.Name)}, n.Tags),
+		})
+		if err != nil {
+			return networkCreateOutput, err
+		}
+		networkCreateOutput.VpcAttachmentIds[spoke.Name] = spokeAttachment.ID()
+
+		allowed := contains(n.AllowedSpokes, spoke.Name)
+		associatedRouteTable := spokeRouteTable
+		if allowed {
+			associatedRouteTable = allowedRouteTable
+		}
+		_, err = ec2transitgateway.NewRouteTableAssociation(ctx, spoke.Name, &ec2transitgateway.RouteTableAssociationArgs{
+			TransitGatewayAttachmentId: spokeAttachment.ID(),
+			TransitGatewayRouteTableId: associatedRouteTable.ID(),
+		})
+		if err != nil {
+			return networkCreateOutput, err
+		}
+
+		// The hub learns the spoke CIDRs for return traffic
+		_, err = ec2transitgateway.NewRouteTablePropagation(ctx, spoke.Name, &ec2transitgateway.RouteTablePropagationArgs{
+			TransitGatewayAttachmentId: spokeAttachment.ID(),
+			TransitGatewayRouteTableId: hubRouteTable.ID(),
+		})
+		if err != nil {
+			return networkCreateOutput, err
+		}
+
+		// Spoke to spoke traffic is dropped, except between allowed spokes
+		_, err = ec2transitgateway.NewRoute(ctx, n.Name+"-spoke-"+spoke.Name, &ec2transitgateway.RouteArgs{
+			DestinationCidrBlock:       spoke.Cidr,
+			Blackhole:                  pulumi.Bool(true),
+			TransitGatewayRouteTableId: spokeRouteTable.ID(),
+		})
+		if err != nil {
+			return networkCreateOutput, err
+		}
+		if allowedRouteTable != nil {
+			allowedRoute := &ec2transitgateway.RouteArgs{
+				DestinationCidrBlock:       spoke.Cidr,
+				TransitGatewayRouteTableId: allowedRouteTable.ID(),
+			}
+			if allowed {
+				allowedRoute.TransitGatewayAttachmentId = spokeAttachment.ID()
+			} else {
+				allowedRoute.Blackhole = pulumi.Bool(true)
+			}
+			_, err = ec2transitgateway.NewRoute(ctx, n.Name+"-allowed-"+spoke.Name, allowedRoute)
+			if err != nil {
+				return networkCreateOutput, err
+			}
+		}
+
+		// Default route of the spoke VPC to the transit gateway
+		for index, routeTableId := range spokeOutput.PrivateRouteTableIds {
+			_, err = ec2.NewRoute(ctx, spoke.Name+"-transit-gateway-"+strconv.Itoa(index), &ec2.RouteArgs{
+				RouteTableId:         routeTableId,
+				DestinationCidrBlock: pulumi.String("0.0.0.0/0"),
+				TransitGatewayId:     transitGateway.ID().ToStringOutput(),
+			}, pulumi.DependsOn([]pulumi.Resource{spokeAttachment}))
+			if err != nil {
+				return networkCreateOutput, err
+			}
+		}
+
+		// Return routes of the hub VPC, NAT gateways live in the public subnets
+		hubRouteTables := append(append(pulumi.StringArray{}, hubOutput.PublicRouteTableIds...), hubOutput.PrivateRouteTableIds...)
+		for index, routeTableId := range hubRouteTables {
+			_, err = ec2.NewRoute(ctx, n.Hub.Name+"-"+spoke.Name+"-"+strconv.Itoa(index), &ec2.RouteArgs{
+				RouteTableId:         routeTableId,
+				DestinationCidrBlock: spoke.Cidr,
+				TransitGatewayId:     transitGateway.ID().ToStringOutput(),
+			}, pulumi.DependsOn([]pulumi.Resource{hubAttachment}))
+			if err != nil {
+				return networkCreateOutput, err
+			}
+		}
+	}
+
+	return networkCreateOutput, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
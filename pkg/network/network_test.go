@@ -0,0 +1,142 @@
+package network
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/echibuogwu/pulumi-aws-go/pkg/vpc"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type mocks struct {
+	sync.Mutex
+	resources map[string]resource.PropertyMap
+}
+
+func newMocks() *mocks {
+	return &mocks{resources: map[string]resource.PropertyMap{}}
+}
+
+func (m *mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	m.Lock()
+	m.resources[args.TypeToken+"::"+args.Name] = args.Inputs
+	m.Unlock()
+	return args.Name + "_id", args.Inputs, nil
+}
+
+func (m *mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+// inputs returns the inputs of a resource, or nil if it was not registered.
+func (m *mocks) inputs(typeToken, name string) resource.PropertyMap {
+	m.Lock()
+	defer m.Unlock()
+	return m.resources[typeToken+"::"+name]
+}
+
+func testVpc(name, cidr string, public, private []string) vpc.Vpc {
+	return vpc.Vpc{
+		Name:          name,
+		Cidr:          pulumi.String(cidr),
+		Azs:           []string{"eu-central-1a", "eu-central-1b"},
+		PublicSubnet:  vpc.Subnet{Cidrs: public},
+		PrivateSubnet: vpc.Subnet{Cidrs: private},
+		NatGateway: vpc.NatGateway{
+			NatGatewayDestinationCidrBlock: pulumi.String("0.0.0.0/0"),
+		},
+	}
+}
+
+func testNetwork(allowedSpokes []string) *Network {
+	return &Network{
+		Name:          "core",
+		AllowedSpokes: allowedSpokes,
+		Hub:           testVpc("egress", "10.0.0.0/16", []string{"10.0.1.0/24", "10.0.2.0/24"}, []string{"10.0.101.0/24", "10.0.102.0/24"}),
+		Spokes: []vpc.Vpc{
+			testVpc("apps", "10.1.0.0/16", nil, []string{"10.1.101.0/24", "10.1.102.0/24"}),
+			testVpc("data", "10.2.0.0/16", nil, []string{"10.2.101.0/24", "10.2.102.0/24"}),
+			testVpc("sandbox", "10.3.0.0/16", nil, []string{"10.3.101.0/24", "10.3.102.0/24"}),
+		},
+	}
+}
+
+func TestCreateNetwork(t *testing.T) {
+	const (
+		route       = "aws:ec2transitgateway/route:Route"
+		association = "aws:ec2transitgateway/routeTableAssociation:RouteTableAssociation"
+	)
+	tests := []struct {
+		name          string
+		allowedSpokes []string
+		// associations maps each spoke to its transit gateway route table
+		associations map[string]string
+		// routes maps each route to its attachment, or to "" for a blackhole
+		routes map[string]string
+	}{
+		{
+			name:         "isolated spokes",
+			associations: map[string]string{"apps": "core-spoke_id", "data": "core-spoke_id", "sandbox": "core-spoke_id"},
+			routes:       map[string]string{"core-spoke-default": "egress_id", "core-spoke-apps": "", "core-spoke-data": "", "core-spoke-sandbox": ""},
+		},
+		{
+			name:          "allowed spokes",
+			allowedSpokes: []string{"apps", "data"},
+			associations:  map[string]string{"apps": "core-allowed_id", "data": "core-allowed_id", "sandbox": "core-spoke_id"},
+			routes: map[string]string{
+				"core-spoke-default":   "egress_id",
+				"core-spoke-apps":      "",
+				"core-spoke-data":      "",
+				"core-spoke-sandbox":   "",
+				"core-allowed-default": "egress_id",
+				"core-allowed-apps":    "apps_id",
+				"core-allowed-data":    "data_id",
+				"core-allowed-sandbox": "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		m := newMocks()
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			_, err := testNetwork(tt.allowedSpokes).CreateNetwork(ctx)
+			return err
+		}, pulumi.WithMocks("project", "stack", m))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		for spoke, routeTableId := range tt.associations {
+			if got := m.inputs(association, spoke)["transitGatewayRouteTableId"].StringValue(); got != routeTableId {
+				t.Errorf("%s: %s is associated with %s, want %s", tt.name, spoke, got, routeTableId)
+			}
+		}
+		for name, attachmentId := range tt.routes {
+			inputs := m.inputs(route, name)
+			if inputs == nil {
+				t.Errorf("%s: no route %s", tt.name, name)
+				continue
+			}
+			blackhole := inputs["blackhole"].IsBool() && inputs["blackhole"].BoolValue()
+			if attachmentId == "" && !blackhole {
+				t.Errorf("%s: %s is not a blackhole", tt.name, name)
+			}
+			if attachmentId != "" && (blackhole || inputs["transitGatewayAttachmentId"].StringValue() != attachmentId) {
+				t.Errorf("%s: %s goes to %v, want %s", tt.name, name, inputs["transitGatewayAttachmentId"], attachmentId)
+			}
+		}
+		if tt.allowedSpokes == nil && m.inputs("aws:ec2transitgateway/routeTable:RouteTable", "core-allowed") != nil {
+			t.Errorf("%s: created a route table for allowed spokes", tt.name)
+		}
+	}
+}
+
+func TestCreateNetworkUnknownAllowedSpoke(t *testing.T) {
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		_, err := testNetwork([]string{"apps", "billing"}).CreateNetwork(ctx)
+		return err
+	}, pulumi.WithMocks("project", "stack", newMocks()))
+	if err == nil {
+		t.Error("expected an error for an allowed spoke that is not a spoke")
+	}
+}
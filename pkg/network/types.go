@@ -0,0 +1,30 @@
+package network
+
+import (
+	"github.com/echibuogwu/pulumi-aws-go/pkg/vpc"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type Network struct {
+	AllowedSpokes  []string
+	Hub            vpc.Vpc
+	Name           string
+	Spokes         []vpc.Vpc
+	Tags           pulumi.StringMap
+	TransitGateway TransitGateway
+}
+
+type TransitGateway struct {
+	AmazonSideAsn pulumi.Int
+	Description   pulumi.String
+}
+
+type NetworkCreateOutput struct {
+	Hub                 *vpc.VpcCreateOutput
+	Spokes              map[string]*vpc.VpcCreateOutput
+	TransitGatewayId    pulumi.IDOutput
+	HubRouteTableId     pulumi.IDOutput
+	SpokeRouteTableId   pulumi.IDOutput
+	AllowedRouteTableId pulumi.IDOutput
+	VpcAttachmentIds    pulumi.StringMap
+}
@@ -0,0 +1,15 @@
+package network
+
+import (
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func mergeTags(tags ...pulumi.StringMap) pulumi.StringMap {
+	merged := make(pulumi.StringMap)
+	for _, tag := range tags {
+		for k, v := range tag {
+			merged[k] = v
+		}
+	}
+	return merged
+}
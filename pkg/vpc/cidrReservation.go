@@ -0,0 +1,63 @@
+package vpc
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// subnetCidr returns the netnum-th block of the subnet cidr extended by
+// newBits, like the cidrsubnet function of Terraform.
+func subnetCidr(cidr string, newBits int, netnum int) (string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	ones, bits := network.Mask.Size()
+	if newBits <= 0 || ones+newBits > bits {
+		return "", fmt.Errorf("cannot extend %s by %d bits", cidr, newBits)
+	}
+	if netnum < 0 || big.NewInt(int64(netnum)).BitLen() > newBits {
+		return "", fmt.Errorf("network number %d does not fit in %d bits of %s", netnum, newBits, cidr)
+	}
+
+	ip := new(big.Int).SetBytes(network.IP)
+	offset := new(big.Int).Lsh(big.NewInt(int64(netnum)), uint(bits-ones-newBits))
+	ip.Or(ip, offset)
+
+	address := make(net.IP, len(network.IP))
+	ip.FillBytes(address)
+	return fmt.Sprintf("%s/%d", address, ones+newBits), nil
+}
+
+// createCidrReservations reserves the CidrReservations of a subnet tier in the
+// subnet of one AZ. It returns the reserved ranges keyed by resource name.
+func (v *Vpc) createCidrReservations(ctx *pulumi.Context, tier string, subnetTier Subnet, index int, subnetId pulumi.IDOutput) (pulumi.StringMap, error) {
+	reserved := pulumi.StringMap{}
+	for reservationIndex, reservation := range subnetTier.CidrReservations {
+		cidr, err := subnetCidr(subnetTier.Cidrs[index], reservation.NewBits, reservation.Netnum)
+		if err != nil {
+			return nil, fmt.Errorf("%s subnet %s: %w", tier, v.Azs[index], err)
+		}
+		reservationType := reservation.ReservationType
+		if reservationType == "" {
+			reservationType = pulumi.String("prefix")
+		}
+
+		name := fmt.Sprintf("%s-%s-%s-%d", v.Name, tier, v.Azs[index], reservationIndex)
+		_, err = ec2.NewSubnetCidrReservation(ctx, name, &ec2.SubnetCidrReservationArgs{
+			CidrBlock:       pulumi.String(cidr),
+			Description:     reservation.Description,
+			ReservationType: reservationType,
+			SubnetId:        subnetId,
+		})
+		if err != nil {
+			return nil, err
+		}
+		reserved[name] = pulumi.String(cidr)
+	}
+	return reserved, nil
+}
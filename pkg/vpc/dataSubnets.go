@@ -0,0 +1,153 @@
+package vpc
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/elasticache"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/redshift"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// createDataSubnets creates one subnet per AZ for a data tier and shares them
+// with the principals of its RamShare. Depending on the RouteTablePolicy the
+// subnets get an isolated route table without internet access (the default),
+// the private route table of their AZ or the public one. PrefixListRoutes
+// need the isolated route table, the shared ones take the routes of their own
+// tier.
+func (v *Vpc) createDataSubnets(ctx *pulumi.Context, tier string, subnetTier Subnet, vpcId pulumi.IDOutput, publicRouteTableId pulumi.IDOutput, privateRouteTables pulumi.StringArray, vpcCreateOutput *VpcCreateOutput) (pulumi.StringArray, pulumi.StringArray, error) {
+	var subnets pulumi.StringArray
+	var subnetArns pulumi.StringArray
+	var routeTables pulumi.StringArray
+
+	switch subnetTier.RouteTablePolicy {
+	case "", "isolated":
+		routeTable, err := ec2.NewRouteTable(ctx, v.Name+"-"+tier, &ec2.RouteTableArgs{
+			VpcId:  vpcId,
+			Routes: ec2.RouteTableRouteArray{},
+			Tags:   mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name + "-" + tier)}, subnetTier.Tags, subnetTier.RouteTableTags),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		routeTables = pulumi.StringArray{routeTable.ID()}
+
+		err = v.createPrefixListRoutes(ctx, tier, subnetTier.PrefixListRoutes, routeTables)
+		if err != nil {
+			return nil, nil, err
+		}
+	case "nat":
+		// The private route tables exist in every AZ, with or without NAT gateways
+		natGatewayCount, _, err := v.natGatewayTopology()
+		if err != nil {
+			return nil, nil, err
+		}
+		if natGatewayCount == 0 {
+			return nil, nil, fmt.Errorf("%s subnets: route table policy %q needs NAT gateways, the nat gateway mode is none", tier, subnetTier.RouteTablePolicy)
+		}
+		routeTables = privateRouteTables
+	case "internet":
+		routeTables = pulumi.StringArray{publicRouteTableId}
+	default:
+		return nil, nil, fmt.Errorf("%s subnets: unknown route table policy %q", tier, subnetTier.RouteTablePolicy)
+	}
+	if len(subnetTier.PrefixListRoutes) > 0 && subnetTier.RouteTablePolicy != "" && subnetTier.RouteTablePolicy != "isolated" {
+		return nil, nil, fmt.Errorf("%s subnets: PrefixListRoutes need the isolated route table policy, got %q", tier, subnetTier.RouteTablePolicy)
+	}
+
+	for index, cidr := range subnetTier.Cidrs {
+		subnet, err := ec2.NewSubnet(ctx, v.Name+"-"+tier+"-"+v.Azs[index], &ec2.SubnetArgs{
+			VpcId:                          vpcId,
+			CidrBlock:                      pulumi.String(cidr),
+			AvailabilityZone:               pulumi.String(v.Azs[index]),
+			PrivateDnsHostnameTypeOnLaunch: subnetTier.PrivateDnsHostnameTypeOnLaunch,
+			Tags:                           mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name + "-" + tier + "-" + v.Azs[index])}, subnetTier.Tags, v.Tags),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		routeTableId := routeTables[len(routeTables)-1]
+		if index < len(routeTables) {
+			routeTableId = routeTables[index]
+		}
+		_, err = ec2.NewRouteTableAssociation(ctx, v.Name+"-"+tier+"-"+v.Azs[index], &ec2.RouteTableAssociationArgs{
+			SubnetId:     subnet.ID(),
+			RouteTableId: routeTableId,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		reserved, err := v.createCidrReservations(ctx, tier, subnetTier, index, subnet.ID())
+		if err != nil {
+			return nil, nil, err
+		}
+		for name, cidr := range reserved {
+			vpcCreateOutput.CidrReservations[name] = cidr
+		}
+		subnets = append(subnets, subnet.ID())
+		subnetArns = append(subnetArns, subnet.Arn)
+	}
+
+	if len(subnetTier.RamShare.Principals) > 0 {
+		shareArn, err := v.shareResources(ctx, tier, subnetTier.RamShare, subnetArns, v.Azs)
+		if err != nil {
+			return nil, nil, err
+		}
+		vpcCreateOutput.RamShareArns[tier] = shareArn
+	}
+	return subnets, routeTables, nil
+}
+
+// createDataTiers creates the ElastiCache and Redshift subnet tiers and their
+// subnet groups.
+func (v *Vpc) createDataTiers(ctx *pulumi.Context, vpcId pulumi.IDOutput, publicRouteTableId pulumi.IDOutput, privateRouteTables pulumi.StringArray, vpcCreateOutput *VpcCreateOutput) error {
+	if len(v.Elasticache.Cidrs) > 0 {
+		subnets, routeTables, err := v.createDataSubnets(ctx, "elasticache", v.Elasticache, vpcId, publicRouteTableId, privateRouteTables, vpcCreateOutput)
+		if err != nil {
+			return err
+		}
+		vpcCreateOutput.ElasticacheSubnetIds = subnets
+		vpcCreateOutput.ElasticacheRouteTableIds = routeTables
+
+		subnetGroupName := v.Elasticache.SubnetGroupName
+		if subnetGroupName == "" {
+			subnetGroupName = pulumi.String(v.Name + "-elasticache")
+		}
+		subnetGroup, err := elasticache.NewSubnetGroup(ctx, v.Name+"-elasticache", &elasticache.SubnetGroupArgs{
+			Name:        subnetGroupName,
+			Description: pulumi.String("ElastiCache subnet group of " + v.Name),
+			SubnetIds:   subnets,
+			Tags:        mergeTags(pulumi.StringMap{"Name": subnetGroupName}, v.Elasticache.SubnetGroupTags, v.Tags),
+		})
+		if err != nil {
+			return err
+		}
+		vpcCreateOutput.ElasticacheSubnetGroupName = subnetGroup.Name
+	}
+
+	if len(v.Redshift.Cidrs) > 0 {
+		subnets, routeTables, err := v.createDataSubnets(ctx, "redshift", v.Redshift, vpcId, publicRouteTableId, privateRouteTables, vpcCreateOutput)
+		if err != nil {
+			return err
+		}
+		vpcCreateOutput.RedshiftSubnetIds = subnets
+		vpcCreateOutput.RedshiftRouteTableIds = routeTables
+
+		subnetGroupName := v.Redshift.SubnetGroupName
+		if subnetGroupName == "" {
+			subnetGroupName = pulumi.String(v.Name + "-redshift")
+		}
+		subnetGroup, err := redshift.NewSubnetGroup(ctx, v.Name+"-redshift", &redshift.SubnetGroupArgs{
+			Name:        subnetGroupName,
+			Description: pulumi.String("Redshift subnet group of " + v.Name),
+			SubnetIds:   subnets,
+			Tags:        mergeTags(pulumi.StringMap{"Name": subnetGroupName}, v.Redshift.SubnetGroupTags, v.Tags),
+		})
+		if err != nil {
+			return err
+		}
+		vpcCreateOutput.RedshiftSubnetGroupName = subnetGroup.Name
+	}
+	return nil
+}
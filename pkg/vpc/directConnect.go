@@ -0,0 +1,71 @@
+package vpc
+
+import (
+	"strconv"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/directconnect"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// createDirectConnect associates a virtual private gateway of the VPC, or a
+// transit gateway, with an existing Direct Connect gateway. With a virtual
+// private gateway the on premises routes are propagated into the private route
+// tables and the isolated data tier route tables.
+func (v *Vpc) createDirectConnect(ctx *pulumi.Context, vpcId pulumi.IDOutput, vpcCreateOutput *VpcCreateOutput) error {
+	associationArgs := &directconnect.GatewayAssociationArgs{
+		DxGatewayId:     v.DirectConnect.DxGatewayId,
+		AllowedPrefixes: v.DirectConnect.AllowedPrefixes,
+	}
+
+	var vpnGateway *ec2.VpnGateway
+	if v.DirectConnect.TransitGatewayId != nil {
+		associationArgs.AssociatedGatewayId = v.DirectConnect.TransitGatewayId
+	} else {
+		vpnGatewayArgs := &ec2.VpnGatewayArgs{
+			VpcId: vpcId,
+			Tags:  mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name)}, v.DirectConnect.Tags, v.Tags),
+		}
+		if v.DirectConnect.AmazonSideAsn != "" {
+			vpnGatewayArgs.AmazonSideAsn = v.DirectConnect.AmazonSideAsn
+		}
+		var err error
+		vpnGateway, err = ec2.NewVpnGateway(ctx, v.Name, vpnGatewayArgs)
+		if err != nil {
+			return err
+		}
+		vpcCreateOutput.VpnGatewayId = vpnGateway.ID()
+		associationArgs.AssociatedGatewayId = vpnGateway.ID()
+	}
+
+	association, err := directconnect.NewGatewayAssociation(ctx, v.Name, associationArgs)
+	if err != nil {
+		return err
+	}
+	vpcCreateOutput.DxGatewayAssociationId = association.DxGatewayAssociationId
+	ctx.Export(v.Name+"-dx-gateway-association", association.DxGatewayAssociationId)
+	ctx.Export(v.Name+"-dx-gateway-allowed-prefixes", association.AllowedPrefixes)
+
+	if vpnGateway == nil {
+		return nil
+	}
+
+	// Route propagation from the virtual private gateway
+	routeTables := append(pulumi.StringArray{}, vpcCreateOutput.PrivateRouteTableIds...)
+	if v.Elasticache.RouteTablePolicy == "" || v.Elasticache.RouteTablePolicy == "isolated" {
+		routeTables = append(routeTables, vpcCreateOutput.ElasticacheRouteTableIds...)
+	}
+	if v.Redshift.RouteTablePolicy == "" || v.Redshift.RouteTablePolicy == "isolated" {
+		routeTables = append(routeTables, vpcCreateOutput.RedshiftRouteTableIds...)
+	}
+	for index, routeTableId := range routeTables {
+		_, err = ec2.NewVpnGatewayRoutePropagation(ctx, v.Name+"-vgw-"+strconv.Itoa(index), &ec2.VpnGatewayRoutePropagationArgs{
+			RouteTableId: routeTableId,
+			VpnGatewayId: vpnGateway.ID(),
+		}, pulumi.DependsOn([]pulumi.Resource{association}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
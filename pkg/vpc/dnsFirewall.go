@@ -0,0 +1,193 @@
+package vpc
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudwatch"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/route53"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// readDomainFile reads one domain per line, skipping blank lines and # comments.
+func readDomainFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var domains []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains = append(domains, line)
+	}
+	return domains, scanner.Err()
+}
+
+// validateDnsFirewall checks the priorities of the rule groups, which need to
+// be unique within the VPC and between 100 and 9900 (both reserved for Firewall
+// Manager), and of the rules, which need to be unique within their group.
+func (v *Vpc) validateDnsFirewall() error {
+	groupPriorities := map[int]string{}
+	for _, ruleGroup := range v.DnsFirewall.RuleGroups {
+		if ruleGroup.Priority <= 100 || ruleGroup.Priority >= 9900 {
+			return fmt.Errorf("dns firewall rule group %s: priority %d is not between 100 and 9900", ruleGroup.Name, ruleGroup.Priority)
+		}
+		if other, ok := groupPriorities[ruleGroup.Priority]; ok {
+			return fmt.Errorf("dns firewall rule groups %s and %s have the same priority %d", other, ruleGroup.Name, ruleGroup.Priority)
+		}
+		groupPriorities[ruleGroup.Priority] = ruleGroup.Name
+
+		rulePriorities := map[int]string{}
+		for _, rule := range ruleGroup.Rules {
+			if rule.Priority <= 0 {
+				return fmt.Errorf("dns firewall rule %s: priority %d is not positive", rule.Name, rule.Priority)
+			}
+			if other, ok := rulePriorities[rule.Priority]; ok {
+				return fmt.Errorf("dns firewall rules %s and %s of %s have the same priority %d", other, rule.Name, ruleGroup.Name, rule.Priority)
+			}
+			rulePriorities[rule.Priority] = rule.Name
+		}
+	}
+	return nil
+}
+
+// createDnsFirewall creates the Route53 Resolver DNS Firewall domain lists and
+// rule groups, associates them with the VPC and sets up query logging.
+func (v *Vpc) createDnsFirewall(ctx *pulumi.Context, vpcId pulumi.IDOutput, vpcCreateOutput *VpcCreateOutput) error {
+	firewall := v.DnsFirewall
+	vpcCreateOutput.DnsFirewallRuleGroupIds = pulumi.StringMap{}
+	if err := v.validateDnsFirewall(); err != nil {
+		return err
+	}
+
+	// Domain lists
+	domainLists := map[string]*route53.ResolverFirewallDomainList{}
+	for _, domainList := range firewall.DomainLists {
+		domains := domainList.Domains
+		if domainList.File != "" {
+			fileDomains, err := readDomainFile(domainList.File)
+			if err != nil {
+				return fmt.Errorf("dns firewall domain list %s: %w", domainList.Name, err)
+			}
+			domains = append(domains, fileDomains...)
+		}
+		list, err := route53.NewResolverFirewallDomainList(ctx, v.Name+"-"+domainList.Name, &route53.ResolverFirewallDomainListArgs{
+			Name:    pulumi.String(v.Name + "-" + domainList.Name),
+			Domains: pulumi.ToStringArray(domains),
+			Tags:    mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name + "-" + domainList.Name)}, firewall.Tags, v.Tags),
+		})
+		if err != nil {
+			return err
+		}
+		domainLists[domainList.Name] = list
+	}
+
+	// Rule groups and their association with the VPC
+	for _, ruleGroup := range firewall.RuleGroups {
+		group, err := route53.NewResolverFirewallRuleGroup(ctx, v.Name+"-"+ruleGroup.Name, &route53.ResolverFirewallRuleGroupArgs{
+			Name: pulumi.String(v.Name + "-" + ruleGroup.Name),
+			Tags: mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name + "-" + ruleGroup.Name)}, firewall.Tags, v.Tags),
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, rule := range ruleGroup.Rules {
+			domainList, ok := domainLists[rule.DomainList]
+			if !ok {
+				return fmt.Errorf("dns firewall rule %s: unknown domain list %q", rule.Name, rule.DomainList)
+			}
+			ruleArgs := &route53.ResolverFirewallRuleArgs{
+				Name:                 pulumi.String(rule.Name),
+				Action:               pulumi.String(rule.Action),
+				FirewallDomainListId: domainList.ID(),
+				FirewallRuleGroupId:  group.ID(),
+				Priority:             pulumi.Int(rule.Priority),
+			}
+			if rule.Action == "BLOCK" {
+				blockResponse := rule.BlockResponse
+				if blockResponse == "" {
+					blockResponse = "NODATA"
+				}
+				ruleArgs.BlockResponse = pulumi.String(blockResponse)
+				if blockResponse == "OVERRIDE" {
+					ruleArgs.BlockOverrideDnsType = pulumi.String("CNAME")
+					ruleArgs.BlockOverrideDomain = pulumi.String(rule.BlockOverrideDomain)
+					ruleArgs.BlockOverrideTtl = pulumi.Int(rule.BlockOverrideTtl)
+				}
+			}
+			_, err = route53.NewResolverFirewallRule(ctx, v.Name+"-"+ruleGroup.Name+"-"+rule.Name, ruleArgs)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = route53.NewResolverFirewallRuleGroupAssociation(ctx, v.Name+"-"+ruleGroup.Name, &route53.ResolverFirewallRuleGroupAssociationArgs{
+			Name:                pulumi.String(v.Name + "-" + ruleGroup.Name),
+			FirewallRuleGroupId: group.ID(),
+			Priority:            pulumi.Int(ruleGroup.Priority),
+			VpcId:               vpcId,
+			Tags:                mergeTags(firewall.Tags, v.Tags),
+		})
+		if err != nil {
+			return err
+		}
+		vpcCreateOutput.DnsFirewallRuleGroupIds[ruleGroup.Name] = group.ID()
+	}
+
+	// Fail open or closed when the firewall is unavailable
+	if len(firewall.RuleGroups) > 0 {
+		firewallFailOpen := "DISABLED"
+		if firewall.FailOpen {
+			firewallFailOpen = "ENABLED"
+		}
+		_, err := route53.NewResolverFirewallConfig(ctx, v.Name, &route53.ResolverFirewallConfigArgs{
+			ResourceId:       vpcId,
+			FirewallFailOpen: pulumi.String(firewallFailOpen),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Query logging to CloudWatch Logs or S3
+	if firewall.QueryLog.Create {
+		destinationArn := firewall.QueryLog.DestinationArn
+		if destinationArn == nil {
+			logGroup, err := cloudwatch.NewLogGroup(ctx, v.Name+"-dns-query-log", &cloudwatch.LogGroupArgs{
+				Name:            pulumi.String("/aws/route53resolver/" + v.Name),
+				RetentionInDays: firewall.QueryLog.RetentionInDays,
+				Tags:            mergeTags(firewall.Tags, v.Tags),
+			})
+			if err != nil {
+				return err
+			}
+			destinationArn = logGroup.Arn
+		}
+		queryLogConfig, err := route53.NewResolverQueryLogConfig(ctx, v.Name, &route53.ResolverQueryLogConfigArgs{
+			Name:           pulumi.String(v.Name),
+			DestinationArn: destinationArn,
+			Tags:           mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name)}, firewall.Tags, v.Tags),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = route53.NewResolverQueryLogConfigAssociation(ctx, v.Name, &route53.ResolverQueryLogConfigAssociationArgs{
+			ResolverQueryLogConfigId: queryLogConfig.ID(),
+			ResourceId:               vpcId,
+		})
+		if err != nil {
+			return err
+		}
+		vpcCreateOutput.DnsQueryLogConfigId = queryLogConfig.ID()
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package vpc
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// edgeIpv6Netnums returns the netnum of the /64 of every edge subnet within
+// the /56 of the VPC, by tier and subnet. The netnums picked by Ipv6Netnums are
+// reserved first, the other subnets take the lowest free ones in order.
+func (v *Vpc) edgeIpv6Netnums() ([][]int, error) {
+	reserved := map[int]string{}
+	for _, edge := range v.EdgeSubnets {
+		for index, netnum := range edge.Ipv6Netnums {
+			if index >= len(edge.Cidrs) {
+				break
+			}
+			if netnum < 0 || netnum > 255 {
+				return nil, fmt.Errorf("edge subnet %s: ipv6 netnum %d is outside 0-255", edge.Name, netnum)
+			}
+			if other, ok := reserved[netnum]; ok {
+				return nil, fmt.Errorf("edge subnet %s: ipv6 netnum %d is already used by %s", edge.Name, netnum, other)
+			}
+			reserved[netnum] = edge.Name
+		}
+	}
+
+	netnums := make([][]int, len(v.EdgeSubnets))
+	next := 0
+	for edgeIndex, edge := range v.EdgeSubnets {
+		for index := range edge.Cidrs {
+			if index < len(edge.Ipv6Netnums) {
+				netnums[edgeIndex] = append(netnums[edgeIndex], edge.Ipv6Netnums[index])
+				continue
+			}
+			for reserved[next] != "" {
+				next++
+			}
+			if next > 255 {
+				return nil, fmt.Errorf("edge subnet %s: no ipv6 /64 left in the VPC block", edge.Name)
+			}
+			netnums[edgeIndex] = append(netnums[edgeIndex], next)
+			next++
+		}
+	}
+	return netnums, nil
+}
+
+// createEdgeSubnets creates the Local Zone and Wavelength subnet tiers. Local
+// Zone subnets reuse the public route table or the private route table of
+// their parent zone, Wavelength subnets route through a carrier gateway. With
+// EnableIpv6 every edge subnet gets a /64 of the Amazon provided block of the
+// VPC, as given by edgeIpv6Netnums.
+func (v *Vpc) createEdgeSubnets(ctx *pulumi.Context, vpc *ec2.Vpc, publicRouteTableId pulumi.IDOutput, privateRouteTables pulumi.StringArray, vpcCreateOutput *VpcCreateOutput) error {
+	vpcCreateOutput.EdgeSubnetIds = pulumi.StringArrayMap{}
+	vpcCreateOutput.EdgeEipIds = pulumi.StringArrayMap{}
+	vpcCreateOutput.CarrierGatewayIds = pulumi.StringMap{}
+	vpcId := vpc.ID()
+
+	ipv6Netnums, err := v.edgeIpv6Netnums()
+	if err != nil {
+		return err
+	}
+	for edgeIndex, edge := range v.EdgeSubnets {
+		if edge.ZoneType != "local-zone" && edge.ZoneType != "wavelength-zone" {
+			return fmt.Errorf("edge subnet %s: unsupported zone type %q", edge.Name, edge.ZoneType)
+		}
+		if len(edge.Zones) < len(edge.Cidrs) {
+			return fmt.Errorf("edge subnet %s: %d cidrs but only %d zones", edge.Name, len(edge.Cidrs), len(edge.Zones))
+		}
+		tierName := v.Name + "-" + edge.Name
+
+		var carrierRouteTable *ec2.RouteTable
+		if edge.ZoneType == "wavelength-zone" {
+			carrierGateway, err := ec2.NewCarrierGateway(ctx, tierName, &ec2.CarrierGatewayArgs{
+				VpcId: vpcId,
+				Tags:  mergeTags(pulumi.StringMap{"Name": pulumi.String(tierName)}, edge.Tags, v.Tags),
+			})
+			if err != nil {
+				return err
+			}
+			vpcCreateOutput.CarrierGatewayIds[edge.Name] = carrierGateway.ID()
+
+			carrierRouteTable, err = ec2.NewRouteTable(ctx, tierName, &ec2.RouteTableArgs{
+				VpcId:  vpcId,
+				Routes: ec2.RouteTableRouteArray{},
+				Tags:   mergeTags(pulumi.StringMap{"Name": pulumi.String(tierName)}, edge.Tags, edge.RouteTableTags),
+			})
+			if err != nil {
+				return err
+			}
+
+			_, err = ec2.NewRoute(ctx, tierName+"-carrier-route", &ec2.RouteArgs{
+				RouteTableId:         carrierRouteTable.ID(),
+				DestinationCidrBlock: pulumi.String("0.0.0.0/0"),
+				CarrierGatewayId:     carrierGateway.ID(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		var edgeSubnets pulumi.StringArray
+		var edgeEipIds pulumi.StringArray
+		for index, cidr := range edge.Cidrs {
+			zoneName := edge.Zones[index]
+			zone, err := aws.GetAvailabilityZone(ctx, &aws.GetAvailabilityZoneArgs{
+				Name:                 pulumi.StringRef(zoneName),
+				AllAvailabilityZones: pulumi.BoolRef(true),
+			}, nil)
+			if err != nil {
+				return err
+			}
+			if edge.ValidateOptIn {
+				if zone.OptInStatus != "opted-in" {
+					return fmt.Errorf("edge subnet %s: zone %s is not opted in (status %q)", edge.Name, zoneName, zone.OptInStatus)
+				}
+				if zone.ZoneType != edge.ZoneType {
+					return fmt.Errorf("edge subnet %s: zone %s is a %s, not a %s", edge.Name, zoneName, zone.ZoneType, edge.ZoneType)
+				}
+			}
+
+			subnetArgs := &ec2.SubnetArgs{
+				VpcId:                          vpcId,
+				CidrBlock:                      pulumi.String(cidr),
+				AvailabilityZone:               pulumi.String(zoneName),
+				MapPublicIpOnLaunch:            edge.MapIpOnLaunch,
+				PrivateDnsHostnameTypeOnLaunch: edge.PrivateDnsHostnameTypeOnLaunch,
+				Tags:                           mergeTags(pulumi.StringMap{"Name": pulumi.String(tierName + "-" + zoneName)}, edge.Tags, v.Tags),
+			}
+			if v.EnableIpv6 {
+				netnum := ipv6Netnums[edgeIndex][index]
+				subnetArgs.Ipv6CidrBlock = vpc.Ipv6CidrBlock.ApplyT(func(cidr string) (string, error) {
+					return subnetCidr(cidr, 8, netnum)
+				}).(pulumi.StringOutput)
+				subnetArgs.AssignIpv6AddressOnCreation = edge.AssignIpv6AddressOnCreation
+			}
+			subnet, err := ec2.NewSubnet(ctx, tierName+"-"+zoneName, subnetArgs)
+			if err != nil {
+				return err
+			}
+
+			var routeTableId pulumi.StringInput
+			switch {
+			case carrierRouteTable != nil:
+				routeTableId = carrierRouteTable.ID()
+			case bool(edge.MapIpOnLaunch):
+				routeTableId = publicRouteTableId
+			default:
+				for azIndex, az := range v.Azs {
+					if az == zone.ParentZoneName && azIndex < len(privateRouteTables) {
+						routeTableId = privateRouteTables[azIndex]
+					}
+				}
+				if routeTableId == nil {
+					return fmt.Errorf("edge subnet %s: parent zone %s of %s has no private route table", edge.Name, zone.ParentZoneName, zoneName)
+				}
+			}
+			_, err = ec2.NewRouteTableAssociation(ctx, tierName+"-"+zoneName, &ec2.RouteTableAssociationArgs{
+				SubnetId:     subnet.ID(),
+				RouteTableId: routeTableId,
+			})
+			if err != nil {
+				return err
+			}
+			edgeSubnets = append(edgeSubnets, subnet.ID())
+
+			// Elastic IPs (carrier IPs in Wavelength) must come from the zone's network border group
+			for i := 0; i < edge.ElasticIpCount; i++ {
+				eip, err := ec2.NewEip(ctx, tierName+"-"+zoneName+"-"+strconv.Itoa(i), &ec2.EipArgs{
+					Domain:             pulumi.String("vpc"),
+					NetworkBorderGroup: pulumi.String(zone.NetworkBorderGroup),
+					Tags:               mergeTags(pulumi.StringMap{"Name": pulumi.String(tierName + "-" + zoneName + "-" + strconv.Itoa(i))}, edge.Tags, v.Tags),
+				})
+				if err != nil {
+					return err
+				}
+				edgeEipIds = append(edgeEipIds, eip.ID())
+			}
+		}
+		vpcCreateOutput.EdgeSubnetIds[edge.Name] = edgeSubnets
+		vpcCreateOutput.EdgeEipIds[edge.Name] = edgeEipIds
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package vpc
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// createIngressInspection sends internet traffic of the public subnets through
+// Gateway Load Balancer endpoints. It creates the appliance subnets and their
+// endpoints, an IGW edge route table that steers inbound traffic for each
+// public subnet to the endpoint of its AZ, and per AZ public route tables that
+// return outbound traffic through the same endpoint. It returns the public
+// route table of every AZ.
+func (v *Vpc) createIngressInspection(ctx *pulumi.Context, vpcId pulumi.IDOutput, igw *ec2.InternetGateway, vpcCreateOutput *VpcCreateOutput) (pulumi.StringArray, error) {
+	inspection := v.IngressInspection
+	if len(inspection.Cidrs) != len(v.PublicSubnet.Cidrs) {
+		return nil, fmt.Errorf("ingress inspection needs one appliance subnet per public subnet, got %d for %d", len(inspection.Cidrs), len(v.PublicSubnet.Cidrs))
+	}
+
+	// Appliance route table, the endpoints reach the internet directly
+	applianceRouteTable, err := ec2.NewRouteTable(ctx, v.Name+"-appliance", &ec2.RouteTableArgs{
+		VpcId:  vpcId,
+		Routes: ec2.RouteTableRouteArray{},
+		Tags:   mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name + "-appliance")}, inspection.Tags, inspection.RouteTableTags),
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = ec2.NewRoute(ctx, v.Name+"-appliance-internet-route", &ec2.RouteArgs{
+		RouteTableId:         applianceRouteTable.ID(),
+		DestinationCidrBlock: pulumi.String("0.0.0.0/0"),
+		GatewayId:            igw.ID(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// IGW edge route table
+	edgeRouteTable, err := ec2.NewRouteTable(ctx, v.Name+"-igw-edge", &ec2.RouteTableArgs{
+		VpcId:  vpcId,
+		Routes: ec2.RouteTableRouteArray{},
+		Tags:   mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name + "-igw-edge")}, inspection.Tags, inspection.RouteTableTags),
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = ec2.NewRouteTableAssociation(ctx, v.Name+"-igw-edge", &ec2.RouteTableAssociationArgs{
+		GatewayId:    igw.ID(),
+		RouteTableId: edgeRouteTable.ID(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	vpcCreateOutput.IngressRouteTableId = edgeRouteTable.ID()
+
+	var applianceSubnets pulumi.StringArray
+	var endpoints pulumi.StringArray
+	var publicRouteTables pulumi.StringArray
+	for index, cidr := range inspection.Cidrs {
+		az := v.Azs[index]
+		subnet, err := ec2.NewSubnet(ctx, v.Name+"-appliance-"+az, &ec2.SubnetArgs{
+			VpcId:            vpcId,
+			CidrBlock:        pulumi.String(cidr),
+			AvailabilityZone: pulumi.String(az),
+			Tags:             mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name + "-appliance-" + az)}, inspection.Tags, v.Tags),
+		})
+		if err != nil {
+			return nil, err
+		}
+		_, err = ec2.NewRouteTableAssociation(ctx, v.Name+"-appliance-"+az, &ec2.RouteTableAssociationArgs{
+			SubnetId:     subnet.ID(),
+			RouteTableId: applianceRouteTable.ID(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		applianceSubnets = append(applianceSubnets, subnet.ID())
+
+		endpoint, err := ec2.NewVpcEndpoint(ctx, v.Name+"-gwlbe-"+az, &ec2.VpcEndpointArgs{
+			VpcId:           vpcId,
+			ServiceName:     inspection.EndpointServiceName,
+			VpcEndpointType: pulumi.String("GatewayLoadBalancer"),
+			SubnetIds:       pulumi.StringArray{subnet.ID()},
+			Tags:            mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name + "-gwlbe-" + az)}, inspection.Tags, v.Tags),
+		})
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint.ID())
+
+		// Inbound traffic for the public subnet of this AZ goes through its endpoint
+		_, err = ec2.NewRoute(ctx, v.Name+"-igw-edge-"+az, &ec2.RouteArgs{
+			RouteTableId:         edgeRouteTable.ID(),
+			DestinationCidrBlock: pulumi.String(v.PublicSubnet.Cidrs[index]),
+			VpcEndpointId:        endpoint.ID().ToStringOutput(),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Return traffic of the public subnet goes back through the same endpoint
+		publicRouteTable, err := ec2.NewRouteTable(ctx, v.Name+"-public-"+az, &ec2.RouteTableArgs{
+			VpcId:  vpcId,
+			Routes: ec2.RouteTableRouteArray{},
+			Tags:   mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name + "-public-" + az)}, v.PublicSubnet.Tags, v.PublicSubnet.RouteTableTags),
+		})
+		if err != nil {
+			return nil, err
+		}
+		_, err = ec2.NewRoute(ctx, v.Name+"-public-gwlbe-"+az, &ec2.RouteArgs{
+			RouteTableId:         publicRouteTable.ID(),
+			DestinationCidrBlock: pulumi.String("0.0.0.0/0"),
+			VpcEndpointId:        endpoint.ID().ToStringOutput(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		publicRouteTables = append(publicRouteTables, publicRouteTable.ID())
+	}
+	vpcCreateOutput.ApplianceSubnetIds = applianceSubnets
+	vpcCreateOutput.GwlbEndpointIds = endpoints
+
+	return publicRouteTables, nil
+}
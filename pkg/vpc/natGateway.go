@@ -0,0 +1,208 @@
+package vpc
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// natGatewayTopology returns how many internet NatGateways to create and, for
+// every entry of Azs, the index of the NatGateway its private route table uses
+// (-1 for none). Without a Mode the SingleNatGateway and OneNatGatewayPerAz
+// flags decide, and every AZ gets its own NatGateway by default.
+func (v *Vpc) natGatewayTopology() (int, []int, error) {
+	mode := v.NatGateway.Mode
+	if mode == "" {
+		mode = "one-per-az"
+		if v.NatGateway.SingleNatGateway && !v.NatGateway.OneNatGatewayPerAz {
+			mode = "single"
+		}
+	}
+
+	var count int
+	switch mode {
+	case "none":
+		count = 0
+	case "single":
+		count = 1
+	case "one-per-az":
+		count = len(v.Azs)
+	case "shared":
+		count = v.NatGateway.Count
+		if count < 1 || count > len(v.Azs) {
+			return 0, nil, fmt.Errorf("shared nat gateway mode needs a Count between 1 and %d, got %d", len(v.Azs), count)
+		}
+	default:
+		return 0, nil, fmt.Errorf("unknown nat gateway mode %q", mode)
+	}
+
+	azNatGateways := make([]int, len(v.Azs))
+	for index, az := range v.Azs {
+		switch {
+		case count == 0:
+			azNatGateways[index] = -1
+		case mode == "shared":
+			azNatGateways[index] = index % count
+			if gateway, ok := v.NatGateway.AzMapping[az]; ok {
+				if gateway < 0 || gateway >= count {
+					return 0, nil, fmt.Errorf("nat gateway mapping for %s points to gateway %d, only %d exist", az, gateway, count)
+				}
+				azNatGateways[index] = gateway
+			}
+		case mode == "single":
+			azNatGateways[index] = 0
+		default:
+			azNatGateways[index] = index
+		}
+	}
+	return count, azNatGateways, nil
+}
+
+// privateNatRouteTarget sets the target of a route from the subnets of a
+// private NAT gateway group to the partner network: the TransitGatewayId or
+// VpnGatewayId of the group, or the virtual private gateway of DirectConnect
+// with UseDirectConnectGateway.
+func (v *Vpc) privateNatRouteTarget(privateNat PrivateNatGateway, vpnGatewayId pulumi.IDOutput, routeArgs *ec2.RouteArgs) error {
+	targets := 0
+	for _, set := range []bool{privateNat.TransitGatewayId != nil, privateNat.VpnGatewayId != nil, privateNat.UseDirectConnectGateway} {
+		if set {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return fmt.Errorf("private nat gateway %s: set one of TransitGatewayId, VpnGatewayId or UseDirectConnectGateway to reach the destination CIDRs", privateNat.Name)
+	}
+
+	switch {
+	case privateNat.TransitGatewayId != nil:
+		routeArgs.TransitGatewayId = privateNat.TransitGatewayId
+	case privateNat.VpnGatewayId != nil:
+		routeArgs.GatewayId = privateNat.VpnGatewayId
+	default:
+		if v.DirectConnect.DxGatewayId == nil || v.DirectConnect.TransitGatewayId != nil || vpnGatewayId.OutputState == nil {
+			return fmt.Errorf("private nat gateway %s: UseDirectConnectGateway needs DirectConnect with a virtual private gateway", privateNat.Name)
+		}
+		routeArgs.GatewayId = vpnGatewayId.ToStringOutput()
+	}
+	return nil
+}
+
+// createPrivateNatGateways creates the private NAT gateway groups. They live
+// in their own subnets and only carry traffic for the destination CIDRs of
+// the group, independently of the internet NAT gateways.
+func (v *Vpc) createPrivateNatGateways(ctx *pulumi.Context, vpcId pulumi.IDOutput, publicRouteTableId pulumi.IDOutput, privateRouteTables pulumi.StringArray, vpcCreateOutput *VpcCreateOutput) error {
+	vpcCreateOutput.PrivateNatGatewayIds = pulumi.StringArrayMap{}
+	vpcCreateOutput.PrivateNatSubnetIds = pulumi.StringArrayMap{}
+	vpcCreateOutput.PrivateNatRouteTableIds = pulumi.StringMap{}
+
+	for _, privateNat := range v.NatGateway.PrivateNatGateways {
+		groupName := v.Name + "-" + privateNat.Name
+		if len(privateNat.SecondaryPrivateIps) > 0 && privateNat.SecondaryPrivateIpCount > 0 {
+			return fmt.Errorf("private nat gateway %s: set either SecondaryPrivateIps or SecondaryPrivateIpCount", privateNat.Name)
+		}
+
+		routeTable, err := ec2.NewRouteTable(ctx, groupName, &ec2.RouteTableArgs{
+			VpcId:  vpcId,
+			Routes: ec2.RouteTableRouteArray{},
+			Tags:   mergeTags(pulumi.StringMap{"Name": pulumi.String(groupName)}, privateNat.Tags, privateNat.RouteTableTags),
+		})
+		if err != nil {
+			return err
+		}
+		vpcCreateOutput.PrivateNatRouteTableIds[privateNat.Name] = routeTable.ID()
+
+		// Routes from the NAT subnets to the partner network
+		for cidrIndex, destination := range privateNat.DestinationCidrBlocks {
+			routeArgs := &ec2.RouteArgs{
+				RouteTableId:         routeTable.ID(),
+				DestinationCidrBlock: pulumi.String(destination),
+			}
+			if err := v.privateNatRouteTarget(privateNat, vpcCreateOutput.VpnGatewayId, routeArgs); err != nil {
+				return err
+			}
+			_, err = ec2.NewRoute(ctx, groupName+"-"+strconv.Itoa(cidrIndex), routeArgs)
+			if err != nil {
+				return err
+			}
+		}
+
+		var subnets pulumi.StringArray
+		var natGateways pulumi.StringArray
+		for index, cidr := range privateNat.Cidrs {
+			subnet, err := ec2.NewSubnet(ctx, groupName+"-"+v.Azs[index], &ec2.SubnetArgs{
+				VpcId:            vpcId,
+				CidrBlock:        pulumi.String(cidr),
+				AvailabilityZone: pulumi.String(v.Azs[index]),
+				Tags:             mergeTags(pulumi.StringMap{"Name": pulumi.String(groupName + "-" + v.Azs[index])}, privateNat.Tags, v.Tags),
+			})
+			if err != nil {
+				return err
+			}
+			_, err = ec2.NewRouteTableAssociation(ctx, groupName+"-"+v.Azs[index], &ec2.RouteTableAssociationArgs{
+				SubnetId:     subnet.ID(),
+				RouteTableId: routeTable.ID(),
+			})
+			if err != nil {
+				return err
+			}
+			subnets = append(subnets, subnet.ID())
+
+			natGatewayArgs := &ec2.NatGatewayArgs{
+				ConnectivityType: pulumi.String("private"),
+				SubnetId:         subnet.ID(),
+				Tags:             mergeTags(pulumi.StringMap{"Name": pulumi.String(groupName + "-" + v.Azs[index])}, privateNat.Tags, v.Tags),
+			}
+			if index < len(privateNat.PrivateIps) {
+				natGatewayArgs.PrivateIp = pulumi.String(privateNat.PrivateIps[index])
+			}
+			if index < len(privateNat.SecondaryPrivateIps) {
+				natGatewayArgs.SecondaryPrivateIpAddresses = pulumi.ToStringArray(privateNat.SecondaryPrivateIps[index])
+			}
+			if privateNat.SecondaryPrivateIpCount > 0 {
+				natGatewayArgs.SecondaryPrivateIpAddressCount = pulumi.Int(privateNat.SecondaryPrivateIpCount)
+			}
+			natGw, err := ec2.NewNatGateway(ctx, groupName+"-"+v.Azs[index], natGatewayArgs)
+			if err != nil {
+				return err
+			}
+			natGateways = append(natGateways, natGw.ID())
+		}
+		vpcCreateOutput.PrivateNatSubnetIds[privateNat.Name] = subnets
+		vpcCreateOutput.PrivateNatGatewayIds[privateNat.Name] = natGateways
+		if len(natGateways) == 0 {
+			continue
+		}
+
+		// Routes from the chosen tiers to the destination CIDRs, through the gateway of the same AZ
+		for _, tier := range privateNat.RouteTiers {
+			var routeTables pulumi.StringArray
+			switch tier {
+			case "public":
+				routeTables = pulumi.StringArray{publicRouteTableId}
+			case "private":
+				routeTables = privateRouteTables
+			default:
+				return fmt.Errorf("private nat gateway %s: unknown route tier %q", privateNat.Name, tier)
+			}
+			for rtIndex, routeTableId := range routeTables {
+				natGatewayId := natGateways[len(natGateways)-1]
+				if rtIndex < len(natGateways) {
+					natGatewayId = natGateways[rtIndex]
+				}
+				for cidrIndex, destination := range privateNat.DestinationCidrBlocks {
+					_, err = ec2.NewRoute(ctx, groupName+"-"+tier+"-"+strconv.Itoa(rtIndex)+"-"+strconv.Itoa(cidrIndex), &ec2.RouteArgs{
+						RouteTableId:         routeTableId,
+						DestinationCidrBlock: pulumi.String(destination),
+						NatGatewayId:         natGatewayId.ToStringOutput(),
+					})
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
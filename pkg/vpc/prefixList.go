@@ -0,0 +1,66 @@
+package vpc
+
+import (
+	"strconv"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func (p *PrefixList) CreatePrefixList(ctx *pulumi.Context) (*PrefixListCreateOutput, error) {
+	prefixListCreateOutput := &PrefixListCreateOutput{}
+
+	addressFamily := p.AddressFamily
+	if addressFamily == "" {
+		addressFamily = "IPv4"
+	}
+	// AWS needs room for at least one entry, also in a list created empty
+	maxEntries := p.MaxEntries
+	if maxEntries == 0 {
+		maxEntries = pulumi.Int(max(len(p.Entries), 1))
+	}
+
+	entries := ec2.ManagedPrefixListEntryTypeArray{}
+	for _, entry := range p.Entries {
+		entries = append(entries, ec2.ManagedPrefixListEntryTypeArgs{
+			Cidr:        pulumi.String(entry.Cidr),
+			Description: pulumi.String(entry.Description),
+		})
+	}
+
+	// Every change to the entries creates a new version of the prefix list
+	prefixList, err := ec2.NewManagedPrefixList(ctx, p.Name, &ec2.ManagedPrefixListArgs{
+		Name:          pulumi.String(p.Name),
+		AddressFamily: addressFamily,
+		MaxEntries:    maxEntries,
+		Entries:       entries,
+		Tags:          mergeTags(pulumi.StringMap{"Name": pulumi.String(p.Name)}, p.Tags),
+	})
+	if err != nil {
+		return prefixListCreateOutput, err
+	}
+	prefixListCreateOutput.PrefixListId = prefixList.ID().ToStringOutput()
+	prefixListCreateOutput.Arn = prefixList.Arn
+	prefixListCreateOutput.Version = prefixList.Version
+
+	return prefixListCreateOutput, nil
+}
+
+func (v *Vpc) createPrefixListRoutes(ctx *pulumi.Context, tier string, routes []PrefixListRoute, routeTableIds pulumi.StringArray) error {
+	for index, routeTableId := range routeTableIds {
+		for _, route := range routes {
+			_, err := ec2.NewRoute(ctx, v.Name+"-"+tier+"-"+route.Name+"-"+strconv.Itoa(index), &ec2.RouteArgs{
+				RouteTableId:            routeTableId,
+				DestinationPrefixListId: route.PrefixListId,
+				GatewayId:               route.GatewayId,
+				NetworkInterfaceId:      route.NetworkInterfaceId,
+				TransitGatewayId:        route.TransitGatewayId,
+				VpcPeeringConnectionId:  route.VpcPeeringConnectionId,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package vpc
+
+import (
+	"strconv"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ram"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// shareResources shares resources with the principals of a RamShare and
+// returns the ARN of the resource share. keys names each resource association.
+func (v *Vpc) shareResources(ctx *pulumi.Context, name string, share RamShare, resourceArns pulumi.StringArray, keys []string) (pulumi.StringOutput, error) {
+	resourceShare, err := ram.NewResourceShare(ctx, v.Name+"-"+name, &ram.ResourceShareArgs{
+		Name:                    pulumi.String(v.Name + "-" + name),
+		AllowExternalPrincipals: share.AllowExternalPrincipals,
+		Tags:                    mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name + "-" + name)}, share.Tags, v.Tags),
+	})
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+
+	for index, resourceArn := range resourceArns {
+		_, err = ram.NewResourceAssociation(ctx, v.Name+"-"+name+"-"+keys[index], &ram.ResourceAssociationArgs{
+			ResourceArn:      resourceArn,
+			ResourceShareArn: resourceShare.Arn,
+		})
+		if err != nil {
+			return pulumi.StringOutput{}, err
+		}
+	}
+
+	for index, principal := range share.Principals {
+		_, err = ram.NewPrincipalAssociation(ctx, v.Name+"-"+name+"-"+strconv.Itoa(index), &ram.PrincipalAssociationArgs{
+			Principal:        pulumi.String(principal),
+			ResourceShareArn: resourceShare.Arn,
+		})
+		if err != nil {
+			return pulumi.StringOutput{}, err
+		}
+	}
+	return resourceShare.Arn, nil
+}
@@ -0,0 +1,117 @@
+package vpc
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// createReachabilityPaths creates a Network Insights path and analysis for each
+// expected connectivity path. A path from a subnet tier starts at a probe ENI in
+// every subnet of the tier, paths without a destination end at the internet
+// gateway. The analysis is replaced, and so run again, whenever the inputs of
+// the path or its Triggers change.
+func (v *Vpc) createReachabilityPaths(ctx *pulumi.Context, igw *ec2.InternetGateway, tierSubnets map[string]pulumi.StringArray, vpcCreateOutput *VpcCreateOutput) error {
+	vpcCreateOutput.NetworkInsightsPathIds = pulumi.StringMap{}
+	vpcCreateOutput.ReachabilityPathFound = pulumi.BoolMap{}
+
+	for _, path := range v.ReachabilityPaths {
+		sources := map[string]pulumi.StringInput{}
+		switch {
+		case path.Source != nil:
+			sources[v.Name+"-"+path.Name] = path.Source
+		case path.SourceTier != "":
+			subnets, ok := tierSubnets[path.SourceTier]
+			if !ok || len(subnets) == 0 {
+				return fmt.Errorf("reachability path %s: no subnets in tier %q", path.Name, path.SourceTier)
+			}
+			for index, subnetId := range subnets {
+				name := v.Name + "-" + path.Name + "-" + v.Azs[index]
+				probe, err := ec2.NewNetworkInterface(ctx, name, &ec2.NetworkInterfaceArgs{
+					SubnetId:    subnetId,
+					Description: pulumi.String("Reachability probe " + path.Name),
+					Tags:        mergeTags(pulumi.StringMap{"Name": pulumi.String(name)}, v.Tags),
+				})
+				if err != nil {
+					return err
+				}
+				sources[name] = probe.ID().ToStringOutput()
+			}
+		default:
+			return fmt.Errorf("reachability path %s: set a Source or a SourceTier", path.Name)
+		}
+
+		var destination pulumi.StringInput = igw.ID().ToStringOutput()
+		if path.Destination != nil {
+			destination = path.Destination
+		}
+		protocol := path.Protocol
+		if protocol == "" {
+			protocol = pulumi.String("tcp")
+		}
+
+		for _, name := range sortedKeys(sources) {
+			source := sources[name]
+			pathArgs := &ec2.NetworkInsightsPathArgs{
+				Source:      source,
+				Destination: destination,
+				Protocol:    protocol,
+				Tags:        mergeTags(pulumi.StringMap{"Name": pulumi.String(name)}, v.Tags),
+			}
+			if path.SourceIp != "" {
+				pathArgs.SourceIp = path.SourceIp
+			}
+			if path.DestinationIp != "" {
+				pathArgs.DestinationIp = path.DestinationIp
+			}
+			if path.DestinationPort != 0 {
+				pathArgs.DestinationPort = path.DestinationPort
+			}
+			insightsPath, err := ec2.NewNetworkInsightsPath(ctx, name, pathArgs)
+			if err != nil {
+				return err
+			}
+
+			triggers := []interface{}{source, destination, protocol, path.SourceIp, path.DestinationIp, path.DestinationPort}
+			for _, trigger := range path.Triggers {
+				triggers = append(triggers, trigger)
+			}
+			trigger := pulumi.All(triggers...).ApplyT(func(args []interface{}) string {
+				return fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprint(args...))))
+			}).(pulumi.StringOutput)
+
+			analysis, err := ec2.NewNetworkInsightsAnalysis(ctx, name, &ec2.NetworkInsightsAnalysisArgs{
+				NetworkInsightsPathId: insightsPath.ID(),
+				WaitForCompletion:     pulumi.Bool(true),
+				Tags:                  mergeTags(pulumi.StringMap{"Name": pulumi.String(name), "Trigger": trigger}, v.Tags),
+			}, pulumi.ReplaceOnChanges([]string{"tags.Trigger"}))
+			if err != nil {
+				return err
+			}
+			found := analysis.PathFound
+			if path.FailOnPathNotFound {
+				found = reachabilityPathFound(name, analysis.PathFound)
+			}
+			vpcCreateOutput.NetworkInsightsPathIds[name] = insightsPath.ID()
+			vpcCreateOutput.ReachabilityPathFound[name] = found
+		}
+	}
+
+	if len(v.ReachabilityPaths) > 0 {
+		ctx.Export(v.Name+"-reachability", vpcCreateOutput.ReachabilityPathFound)
+	}
+	return nil
+}
+
+// reachabilityPathFound fails the deployment through the exported output when
+// the analysis of a path finds no path.
+func reachabilityPathFound(name string, pathFound pulumi.BoolOutput) pulumi.BoolOutput {
+	return pathFound.ApplyT(func(found bool) (bool, error) {
+		if !found {
+			return false, fmt.Errorf("reachability path %s: no path found", name)
+		}
+		return true, nil
+	}).(pulumi.BoolOutput)
+}
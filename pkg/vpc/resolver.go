@@ -0,0 +1,171 @@
+package vpc
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/route53"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// createResolver creates the Route53 Resolver endpoints for hybrid DNS and the
+// forwarding rules of the outbound endpoint.
+func (v *Vpc) createResolver(ctx *pulumi.Context, vpcId pulumi.IDOutput, tierSubnets map[string]pulumi.StringArray, vpcCreateOutput *VpcCreateOutput) error {
+	if len(v.Resolver.Rules) > 0 && !v.Resolver.Outbound.Create {
+		return fmt.Errorf("resolver rules need an outbound resolver endpoint")
+	}
+	if !v.Resolver.Inbound.Create && !v.Resolver.Outbound.Create {
+		return nil
+	}
+
+	// Resolver Security Group
+	resolverSg, err := ec2.NewSecurityGroup(ctx, v.Name+"-resolver", &ec2.SecurityGroupArgs{
+		Description: pulumi.String("Route53 Resolver endpoints of " + v.Name),
+		VpcId:       vpcId,
+		Tags:        mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name + "-resolver")}, v.Resolver.Tags, v.Tags),
+	})
+	if err != nil {
+		return err
+	}
+	vpcCreateOutput.ResolverSecurityGroupId = resolverSg.ID()
+
+	allowedCidrs := pulumi.StringArray{v.Cidr}
+	for _, cidr := range v.Resolver.AllowedCidrs {
+		allowedCidrs = append(allowedCidrs, pulumi.String(cidr))
+	}
+	for _, protocol := range []string{"tcp", "udp"} {
+		_, err = ec2.NewSecurityGroupRule(ctx, v.Name+"-resolver-ingress-"+protocol, &ec2.SecurityGroupRuleArgs{
+			Type:            pulumi.String("ingress"),
+			FromPort:        pulumi.Int(53),
+			ToPort:          pulumi.Int(53),
+			Protocol:        pulumi.String(protocol),
+			CidrBlocks:      allowedCidrs,
+			Description:     pulumi.String("DNS queries to the inbound endpoint"),
+			SecurityGroupId: resolverSg.ID(),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = ec2.NewSecurityGroupRule(ctx, v.Name+"-resolver-egress-"+protocol, &ec2.SecurityGroupRuleArgs{
+			Type:            pulumi.String("egress"),
+			FromPort:        pulumi.Int(53),
+			ToPort:          pulumi.Int(53),
+			Protocol:        pulumi.String(protocol),
+			CidrBlocks:      pulumi.StringArray{pulumi.String("0.0.0.0/0")},
+			Description:     pulumi.String("DNS queries from the outbound endpoint"),
+			SecurityGroupId: resolverSg.ID(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	endpoints := []struct {
+		name      string
+		direction string
+		endpoint  ResolverEndpoint
+	}{
+		{"resolver-inbound", "INBOUND", v.Resolver.Inbound},
+		{"resolver-outbound", "OUTBOUND", v.Resolver.Outbound},
+	}
+	var outboundEndpoint *route53.ResolverEndpoint
+	for _, e := range endpoints {
+		if !e.endpoint.Create {
+			continue
+		}
+		subnetTier := e.endpoint.SubnetTier
+		if subnetTier == "" {
+			subnetTier = "private"
+		}
+		subnets, ok := tierSubnets[subnetTier]
+		if !ok {
+			return fmt.Errorf("resolver endpoint: unknown subnet tier %q", subnetTier)
+		}
+		ipAddresses := route53.ResolverEndpointIpAddressArray{}
+		for index, subnetId := range subnets {
+			ipAddress := route53.ResolverEndpointIpAddressArgs{
+				SubnetId: subnetId,
+			}
+			if index < len(e.endpoint.IpAddresses) {
+				ipAddress.Ip = pulumi.String(e.endpoint.IpAddresses[index])
+			}
+			ipAddresses = append(ipAddresses, ipAddress)
+		}
+
+		endpoint, err := route53.NewResolverEndpoint(ctx, v.Name+"-"+e.name, &route53.ResolverEndpointArgs{
+			Name:             pulumi.String(v.Name + "-" + e.name),
+			Direction:        pulumi.String(e.direction),
+			IpAddresses:      ipAddresses,
+			SecurityGroupIds: pulumi.StringArray{resolverSg.ID()},
+			Tags:             mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name + "-" + e.name)}, v.Resolver.Tags, v.Tags),
+		})
+		if err != nil {
+			return err
+		}
+		if e.direction == "INBOUND" {
+			vpcCreateOutput.ResolverInboundEndpointId = endpoint.ID()
+			vpcCreateOutput.ResolverInboundIps = endpoint.IpAddresses.ApplyT(func(ipAddresses []route53.ResolverEndpointIpAddress) []string {
+				ips := []string{}
+				for _, ipAddress := range ipAddresses {
+					if ipAddress.Ip != nil {
+						ips = append(ips, *ipAddress.Ip)
+					}
+				}
+				return ips
+			}).(pulumi.StringArrayOutput)
+		} else {
+			vpcCreateOutput.ResolverOutboundEndpointId = endpoint.ID()
+			outboundEndpoint = endpoint
+		}
+	}
+
+	// Forwarding rules
+	vpcCreateOutput.ResolverRuleIds = pulumi.StringMap{}
+	var ruleArns pulumi.StringArray
+	var ruleNames []string
+	for _, rule := range v.Resolver.Rules {
+		targetIps := route53.ResolverRuleTargetIpArray{}
+		for _, target := range rule.TargetIps {
+			targetIp := route53.ResolverRuleTargetIpArgs{
+				Ip: pulumi.String(target.Ip),
+			}
+			if target.Port != 0 {
+				targetIp.Port = pulumi.Int(target.Port)
+			}
+			targetIps = append(targetIps, targetIp)
+		}
+
+		resolverRule, err := route53.NewResolverRule(ctx, v.Name+"-"+rule.Name, &route53.ResolverRuleArgs{
+			Name:               pulumi.String(v.Name + "-" + rule.Name),
+			DomainName:         pulumi.String(rule.DomainName),
+			RuleType:           pulumi.String("FORWARD"),
+			ResolverEndpointId: outboundEndpoint.ID(),
+			TargetIps:          targetIps,
+			Tags:               mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name + "-" + rule.Name)}, v.Resolver.Tags, v.Tags),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = route53.NewResolverRuleAssociation(ctx, v.Name+"-"+rule.Name, &route53.ResolverRuleAssociationArgs{
+			ResolverRuleId: resolverRule.ID(),
+			VpcId:          vpcId,
+		})
+		if err != nil {
+			return err
+		}
+		vpcCreateOutput.ResolverRuleIds[rule.Name] = resolverRule.ID()
+		ruleArns = append(ruleArns, resolverRule.Arn)
+		ruleNames = append(ruleNames, rule.Name)
+	}
+
+	// Share the forwarding rules with RAM
+	if len(v.Resolver.RamShare.Principals) > 0 && len(ruleArns) > 0 {
+		shareArn, err := v.shareResources(ctx, "resolver-rules", v.Resolver.RamShare, ruleArns, ruleNames)
+		if err != nil {
+			return err
+		}
+		vpcCreateOutput.RamShareArns["resolver-rules"] = shareArn
+	}
+	return nil
+}
@@ -9,10 +9,15 @@ type Vpc struct {
 	Cidr                             pulumi.String
 	Database                         Subnet
 	DhcpOption                       DhcpOption
+	DirectConnect                    DirectConnect
+	DnsFirewall                      DnsFirewall
+	EdgeSubnets                      []EdgeSubnet
+	Elasticache                      Subnet
 	EnableDnsHostnames               pulumi.Bool
 	EnableDnsSupport                 pulumi.Bool
 	EnableNetworkAddressUsageMetrics pulumi.Bool
 	EnableIpv6                       pulumi.Bool
+	IngressInspection                IngressInspection
 	InstanceTenancy                  pulumi.String
 	InternetGateway                  InternetGateway
 	Ipv4IpamPoolId                   pulumi.String
@@ -26,7 +31,11 @@ type Vpc struct {
 	NetworkAcl                       NetworkAcl
 	PrivateSubnet                    Subnet
 	PublicSubnet                     Subnet
+	ReachabilityPaths                []ReachabilityPath
+	Redshift                         Subnet
+	Resolver                         Resolver
 	SecondaryCidr                    pulumi.StringArray
+	SkipLegacyAliases                bool
 	Tags                             pulumi.StringMap
 	UseIpamPool                      pulumi.Bool
 }
@@ -41,8 +50,116 @@ type DhcpOption struct {
 	Tags               pulumi.StringMap
 }
 
+type CidrReservation struct {
+	Description     pulumi.String
+	NewBits         int
+	Netnum          int
+	ReservationType pulumi.String
+}
+
+type DirectConnect struct {
+	AllowedPrefixes  pulumi.StringArray
+	AmazonSideAsn    pulumi.String
+	DxGatewayId      pulumi.StringInput
+	Tags             pulumi.StringMap
+	TransitGatewayId pulumi.StringInput
+}
+
+type DnsFirewall struct {
+	DomainLists []DnsFirewallDomainList
+	FailOpen    bool
+	QueryLog    DnsQueryLog
+	RuleGroups  []DnsFirewallRuleGroup
+	Tags        pulumi.StringMap
+}
+
+type DnsFirewallDomainList struct {
+	Domains []string
+	File    string
+	Name    string
+}
+
+type DnsFirewallRuleGroup struct {
+	Name     string
+	Priority int
+	Rules    []DnsFirewallRule
+}
+
+type DnsFirewallRule struct {
+	Action              string
+	BlockOverrideDomain string
+	BlockOverrideTtl    int
+	BlockResponse       string
+	DomainList          string
+	Name                string
+	Priority            int
+}
+
+type DnsQueryLog struct {
+	Create          bool
+	DestinationArn  pulumi.StringInput
+	RetentionInDays pulumi.Int
+}
+
+type EdgeSubnet struct {
+	AssignIpv6AddressOnCreation    pulumi.Bool
+	Cidrs                          []string
+	ElasticIpCount                 int
+	Ipv6Netnums                    []int
+	MapIpOnLaunch                  pulumi.Bool
+	Name                           string
+	PrivateDnsHostnameTypeOnLaunch pulumi.String
+	RouteTableTags                 pulumi.StringMap
+	Tags                           pulumi.StringMap
+	ValidateOptIn                  bool
+	Zones                          []string
+	ZoneType                       string
+}
+
+type ReachabilityPath struct {
+	Destination        pulumi.StringInput
+	DestinationIp      pulumi.String
+	DestinationPort    pulumi.Int
+	FailOnPathNotFound bool
+	Name               string
+	Protocol           pulumi.String
+	Source             pulumi.StringInput
+	SourceIp           pulumi.String
+	SourceTier         string
+	// Triggers run the analysis again when one of them changes, e.g. the IDs
+	// of the route tables or security groups along the path.
+	Triggers pulumi.StringArray
+}
+
+type Resolver struct {
+	AllowedCidrs []string
+	Inbound      ResolverEndpoint
+	Outbound     ResolverEndpoint
+	RamShare     RamShare
+	Rules        []ResolverRule
+	Tags         pulumi.StringMap
+}
+
+type ResolverEndpoint struct {
+	Create      bool
+	IpAddresses []string
+	SubnetTier  string
+}
+
+type ResolverRule struct {
+	DomainName string
+	Name       string
+	TargetIps  []ResolverTargetIp
+}
+
+type ResolverTargetIp struct {
+	Ip   string
+	Port int
+}
+
 type Subnet struct {
 	AssignIpv6AddressOnCreation             pulumi.Bool
+	CidrReservations                        []CidrReservation
 	Cidrs                                   []string
 	CreateDatabaseInternetGatewayRoute      pulumi.Bool
 	CreateDatabaseNatGatewayRoute           pulumi.Bool
@@ -56,12 +173,45 @@ type Subnet struct {
 	Ipv6Native                              pulumi.Bool
 	Ipv6Prefixes                            pulumi.StringArray
 	MapIpOnLaunch                           pulumi.Bool
+	PrefixListRoutes                        []PrefixListRoute
 	PrivateDnsHostnameTypeOnLaunch          pulumi.String
+	RamShare                                RamShare
+	RouteTablePolicy                        string
 	RouteTableTags                          pulumi.StringMap
+	SubnetGroupName                         pulumi.String
+	SubnetGroupTags                         pulumi.StringMap
 	Tags                                    pulumi.StringMap
 	TagsPerAz                               pulumi.StringMap
 }
 
+type PrefixList struct {
+	AddressFamily pulumi.String
+	Entries       []PrefixListEntry
+	MaxEntries    pulumi.Int
+	Name          string
+	Tags          pulumi.StringMap
+}
+
+type PrefixListEntry struct {
+	Cidr        string
+	Description string
+}
+
+type PrefixListRoute struct {
+	GatewayId              pulumi.StringPtrInput
+	Name                   string
+	NetworkInterfaceId     pulumi.StringPtrInput
+	PrefixListId           pulumi.StringPtrInput
+	TransitGatewayId       pulumi.StringPtrInput
+	VpcPeeringConnectionId pulumi.StringPtrInput
+}
+
+type RamShare struct {
+	AllowExternalPrincipals pulumi.Bool
+	Principals              []string
+	Tags                    pulumi.StringMap
+}
+
 type NetworkAcl struct {
 	AclTags             pulumi.StringMap
 	DedicatedNetworkAcl pulumi.Bool
@@ -69,30 +219,95 @@ type NetworkAcl struct {
 	OutboundAclRules    []pulumi.StringMap
 }
 
+type IngressInspection struct {
+	Cidrs               []string
+	EndpointServiceName pulumi.StringInput
+	RouteTableTags      pulumi.StringMap
+	Tags                pulumi.StringMap
+}
+
 type InternetGateway struct {
 	CreateEgressOnlyIgw pulumi.Bool
 	IgwTags             pulumi.StringMap
 }
 
 type NatGateway struct {
+	AzMapping                      map[string]int
+	Count                          int
 	ExternalNatIpIds               pulumi.StringArray
 	ExternalNatIps                 pulumi.StringArray
+	Mode                           string
 	NatEipTags                     pulumi.StringMap
 	NatGatewayDestinationCidrBlock pulumi.String
 	NatGatewayTags                 pulumi.StringMap
 	OneNatGatewayPerAz             pulumi.Bool
+	PrivateNatGateways             []PrivateNatGateway
 	ReuseNatIps                    pulumi.Bool
 	SingleNatGateway               pulumi.Bool
 }
 
+type PrivateNatGateway struct {
+	Cidrs                 []string
+	DestinationCidrBlocks []string
+	Name                  string
+	PrivateIps            []string
+	RouteTableTags        pulumi.StringMap
+	RouteTiers            []string
+	// SecondaryPrivateIps holds the secondary IPs of the gateway of each AZ,
+	// SecondaryPrivateIpCount lets AWS pick them for every gateway.
+	SecondaryPrivateIpCount int
+	SecondaryPrivateIps     [][]string
+	Tags                    pulumi.StringMap
+	TransitGatewayId        pulumi.StringInput
+	// UseDirectConnectGateway routes the destination CIDRs through the
+	// virtual private gateway created for DirectConnect.
+	UseDirectConnectGateway bool
+	VpnGatewayId            pulumi.StringInput
+}
+
 type VpcCreateOutput struct {
 	VpcId                       pulumi.IDOutput
 	DhcpOptionId                pulumi.IDOutput
 	InternetGatewayId           pulumi.IDOutput
 	PublicRouteTableId          pulumi.IDOutput
+	PublicRouteTableIds         pulumi.StringArray
 	PublicSubnetIds             pulumi.StringArray
 	PrivateRouteTableIds        pulumi.StringArray
 	PrivateSubnetsIds           pulumi.StringArray
 	NatGatewaysIds              pulumi.StringArray
 	EgressOnlyInternetGatewayId pulumi.IDOutput
+	RamShareArns                pulumi.StringMap
+	EdgeSubnetIds               pulumi.StringArrayMap
+	EdgeEipIds                  pulumi.StringArrayMap
+	CarrierGatewayIds           pulumi.StringMap
+	PrivateNatGatewayIds        pulumi.StringArrayMap
+	PrivateNatSubnetIds         pulumi.StringArrayMap
+	PrivateNatRouteTableIds     pulumi.StringMap
+	ResolverSecurityGroupId     pulumi.IDOutput
+	ResolverInboundEndpointId   pulumi.IDOutput
+	ResolverInboundIps          pulumi.StringArrayOutput
+	ResolverOutboundEndpointId  pulumi.IDOutput
+	ResolverRuleIds             pulumi.StringMap
+	DnsFirewallRuleGroupIds     pulumi.StringMap
+	DnsQueryLogConfigId         pulumi.IDOutput
+	ElasticacheSubnetIds        pulumi.StringArray
+	ElasticacheRouteTableIds    pulumi.StringArray
+	ElasticacheSubnetGroupName  pulumi.StringOutput
+	RedshiftSubnetIds           pulumi.StringArray
+	RedshiftRouteTableIds       pulumi.StringArray
+	RedshiftSubnetGroupName     pulumi.StringOutput
+	ApplianceSubnetIds          pulumi.StringArray
+	GwlbEndpointIds             pulumi.StringArray
+	IngressRouteTableId         pulumi.IDOutput
+	VpnGatewayId                pulumi.IDOutput
+	DxGatewayAssociationId      pulumi.StringOutput
+	CidrReservations            pulumi.StringMap
+	NetworkInsightsPathIds      pulumi.StringMap
+	ReachabilityPathFound       pulumi.BoolMap
+}
+
+type PrefixListCreateOutput struct {
+	PrefixListId pulumi.StringOutput
+	Arn          pulumi.StringOutput
+	Version      pulumi.IntOutput
 }
@@ -1,9 +1,11 @@
 package vpc
 
 import (
+	"fmt"
+	"sort"
 	"strconv"
 
-	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 	// "github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
 )
@@ -18,17 +20,52 @@ func mergeTags(tags ...pulumi.StringMap) pulumi.StringMap {
 	return merged
 }
 
+// sortedKeys returns the keys of a map in a stable order.
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// legacyName keeps resources that were created before every logical name was
+// derived from Vpc.Name from being replaced on the next update.
+func (v *Vpc) legacyName(name string) pulumi.ResourceOption {
+	if v.SkipLegacyAliases {
+		return pulumi.Aliases(nil)
+	}
+	return pulumi.Aliases([]pulumi.Alias{{Name: pulumi.String(name)}})
+}
+
 func (v *Vpc) CreateVpc(ctx *pulumi.Context) (*VpcCreateOutput, error) {
-	vpcCreateOutput := &VpcCreateOutput{}
+	vpcCreateOutput := &VpcCreateOutput{
+		CidrReservations: pulumi.StringMap{},
+		RamShareArns:     pulumi.StringMap{},
+	}
+	// The Database tier has no subnets of its own yet
+	if len(v.Database.PrefixListRoutes) > 0 || len(v.Database.RamShare.Principals) > 0 {
+		return vpcCreateOutput, fmt.Errorf("database subnets are not created, PrefixListRoutes and RamShare are not supported on them")
+	}
+
 	// Create VPC
-	vpc, err := ec2.NewVpc(ctx, v.Name, &ec2.VpcArgs{
+	vpcArgs := &ec2.VpcArgs{
 		CidrBlock:                        v.Cidr,
 		InstanceTenancy:                  v.InstanceTenancy,
 		EnableDnsHostnames:               v.EnableDnsHostnames,
 		EnableDnsSupport:                 v.EnableDnsSupport,
 		EnableNetworkAddressUsageMetrics: v.EnableNetworkAddressUsageMetrics,
 		Tags:                             mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name)}, v.Tags),
-	})
+	}
+	// Amazon provided Ipv6 block, optionally advertised from an edge zone's network border group
+	if v.EnableIpv6 {
+		vpcArgs.AssignGeneratedIpv6CidrBlock = v.EnableIpv6
+		if v.Ipv6CidrBlockNetworkBorderGroup != "" {
+			vpcArgs.Ipv6CidrBlockNetworkBorderGroup = v.Ipv6CidrBlockNetworkBorderGroup
+		}
+	}
+	vpc, err := ec2.NewVpc(ctx, v.Name, vpcArgs)
 	if err != nil {
 		return vpcCreateOutput, err
 	}
@@ -47,23 +84,23 @@ func (v *Vpc) CreateVpc(ctx *pulumi.Context) (*VpcCreateOutput, error) {
 
 	// Dhcp Options
 	if v.DhcpOption.Create {
-		dhcpOptionId, err := ec2.NewVpcDhcpOptions(ctx, v.DhcpOption.DomainName, &ec2.VpcDhcpOptionsArgs{
+		dhcpOptionId, err := ec2.NewVpcDhcpOptions(ctx, v.Name, &ec2.VpcDhcpOptionsArgs{
 			DomainNameServers:  v.DhcpOption.DomainNameServers,
 			NetbiosNameServers: v.DhcpOption.NetbiosNameServers,
 			NetbiosNodeType:    v.DhcpOption.NetbiosNodeType,
 			NtpServers:         v.DhcpOption.NtpServers,
 			Tags:               mergeTags(pulumi.StringMap{"Name": pulumi.String(v.DhcpOption.DomainName)}, v.DhcpOption.Tags, v.Tags),
-		})
+		}, v.legacyName(v.DhcpOption.DomainName))
 		if err != nil {
 			return vpcCreateOutput, err
 		}
 		vpcCreateOutput.DhcpOptionId = dhcpOptionId.ID()
 
 		// Dhcp Options Association
-		_, err = ec2.NewVpcDhcpOptionsAssociation(ctx, "dnsResolver", &ec2.VpcDhcpOptionsAssociationArgs{
+		_, err = ec2.NewVpcDhcpOptionsAssociation(ctx, v.Name+"-dnsResolver", &ec2.VpcDhcpOptionsAssociationArgs{
 			VpcId:         vpc.ID(),
 			DhcpOptionsId: dhcpOptionId.ID(),
-		})
+		}, v.legacyName("dnsResolver"))
 		if err != nil {
 			return vpcCreateOutput, err
 		}
@@ -93,11 +130,11 @@ func (v *Vpc) CreateVpc(ctx *pulumi.Context) (*VpcCreateOutput, error) {
 	}
 
 	// create public route table
-	publicRouteTable, err := ec2.NewRouteTable(ctx, "public", &ec2.RouteTableArgs{
+	publicRouteTable, err := ec2.NewRouteTable(ctx, v.Name+"-public", &ec2.RouteTableArgs{
 		VpcId:  vpc.ID(),
 		Routes: ec2.RouteTableRouteArray{},
-		Tags:   mergeTags(pulumi.StringMap{"Name": pulumi.String("public")}, v.PublicSubnet.Tags, v.PublicSubnet.RouteTableTags),
-	})
+		Tags:   mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name + "-public")}, v.PublicSubnet.Tags, v.PublicSubnet.RouteTableTags),
+	}, v.legacyName("public"))
 	if err != nil {
 		return vpcCreateOutput, err
 	}
@@ -113,8 +150,19 @@ func (v *Vpc) CreateVpc(ctx *pulumi.Context) (*VpcCreateOutput, error) {
 		return vpcCreateOutput, err
 	}
 
+	// Route public subnets through Gateway Load Balancer endpoints
+	var inspectedRouteTables pulumi.StringArray
+	if v.IngressInspection.EndpointServiceName != nil {
+		inspectedRouteTables, err = v.createIngressInspection(ctx, vpc.ID(), igw, vpcCreateOutput)
+		if err != nil {
+			return vpcCreateOutput, err
+		}
+	}
+	vpcCreateOutput.PublicRouteTableIds = append(pulumi.StringArray{publicRouteTable.ID()}, inspectedRouteTables...)
+
 	// Create public Subnets
 	var publicSubnets pulumi.StringArray
+	var publicSubnetArns pulumi.StringArray
 	for index, subnet := range v.PublicSubnet.Cidrs {
 		subnet, err := ec2.NewSubnet(ctx, v.Name+"-public-"+v.Azs[index], &ec2.SubnetArgs{
 			VpcId:                                   vpc.ID(),
@@ -131,26 +179,45 @@ func (v *Vpc) CreateVpc(ctx *pulumi.Context) (*VpcCreateOutput, error) {
 		if err != nil {
 			return vpcCreateOutput, err
 		}
+		var publicSubnetRouteTable pulumi.StringInput = publicRouteTable.ID()
+		if index < len(inspectedRouteTables) {
+			publicSubnetRouteTable = inspectedRouteTables[index]
+		}
 		_, err = ec2.NewRouteTableAssociation(ctx, v.Name+"-public-"+v.Azs[index], &ec2.RouteTableAssociationArgs{
 			SubnetId:     subnet.ID(),
-			RouteTableId: publicRouteTable.ID(),
+			RouteTableId: publicSubnetRouteTable,
 		})
 		if err != nil {
 			return vpcCreateOutput, err
 		}
+		reserved, err := v.createCidrReservations(ctx, "public", v.PublicSubnet, index, subnet.ID())
+		if err != nil {
+			return vpcCreateOutput, err
+		}
+		for name, cidr := range reserved {
+			vpcCreateOutput.CidrReservations[name] = cidr
+		}
 		publicSubnets = append(publicSubnets, subnet.ID())
+		publicSubnetArns = append(publicSubnetArns, subnet.Arn)
 	}
 	vpcCreateOutput.PublicSubnetIds = publicSubnets
 
+	// Prefix list routes
+	err = v.createPrefixListRoutes(ctx, "public", v.PublicSubnet.PrefixListRoutes, vpcCreateOutput.PublicRouteTableIds)
+	if err != nil {
+		return vpcCreateOutput, err
+	}
+
 	// create NatGateway
-	var natGatewayCount int
 	var natGateways pulumi.StringArray
 	var privateRouteTables pulumi.StringArray
 
-	if v.NatGateway.SingleNatGateway {
-		natGatewayCount = 1
-	} else {
-		natGatewayCount = len(v.Azs)
+	natGatewayCount, azNatGateways, err := v.natGatewayTopology()
+	if err != nil {
+		return vpcCreateOutput, err
+	}
+	if natGatewayCount > len(publicSubnets) {
+		return vpcCreateOutput, fmt.Errorf("%d nat gateways need as many public subnets, got %d", natGatewayCount, len(publicSubnets))
 	}
 
 	for i := 0; i < natGatewayCount; i++ {
@@ -162,44 +229,59 @@ func (v *Vpc) CreateVpc(ctx *pulumi.Context) (*VpcCreateOutput, error) {
 		}
 
 		// Create Natgateway
-		natGw, err := ec2.NewNatGateway(ctx, "natgateway-"+strconv.Itoa(i), &ec2.NatGatewayArgs{
+		natGw, err := ec2.NewNatGateway(ctx, v.Name+"-natgateway-"+strconv.Itoa(i), &ec2.NatGatewayArgs{
 			AllocationId: eip.ID(),
 			SubnetId:     publicSubnets[i],
 			Tags:         mergeTags(v.NatGateway.NatGatewayTags, v.Tags),
 		}, pulumi.DependsOn([]pulumi.Resource{
 			igw,
-		}))
+		}), v.legacyName("natgateway-"+strconv.Itoa(i)))
 		if err != nil {
 			return vpcCreateOutput, err
 		}
 		natGateways = append(natGateways, natGw.ID())
+	}
 
-		// create private route table
-		privateRouteTable, err := ec2.NewRouteTable(ctx, "private-"+v.Azs[i], &ec2.RouteTableArgs{
+	// create one private route table per AZ, routed to the NatGateway mapped to that AZ
+	natGatewayDestinationCidrBlock := v.NatGateway.NatGatewayDestinationCidrBlock
+	if natGatewayDestinationCidrBlock == "" {
+		natGatewayDestinationCidrBlock = "0.0.0.0/0"
+	}
+	for i, az := range v.Azs {
+		privateRouteTable, err := ec2.NewRouteTable(ctx, v.Name+"-private-"+az, &ec2.RouteTableArgs{
 			VpcId:  vpc.ID(),
 			Routes: ec2.RouteTableRouteArray{},
-			Tags:   mergeTags(pulumi.StringMap{"Name": pulumi.String("private-" + v.Azs[i])}, v.PrivateSubnet.Tags, v.PrivateSubnet.RouteTableTags),
-		})
+			Tags:   mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name + "-private-" + az)}, v.PrivateSubnet.Tags, v.PrivateSubnet.RouteTableTags),
+		}, v.legacyName("private-"+az))
 		if err != nil {
 			return vpcCreateOutput, err
 		}
+		privateRouteTables = append(privateRouteTables, privateRouteTable.ID())
 
+		if azNatGateways[i] < 0 {
+			continue
+		}
 		// Create private to natgateway route
-		_, err = ec2.NewRoute(ctx, "private-natgateway-"+v.Azs[i], &ec2.RouteArgs{
+		_, err = ec2.NewRoute(ctx, v.Name+"-private-natgateway-"+az, &ec2.RouteArgs{
 			RouteTableId:         privateRouteTable.ID(),
-			DestinationCidrBlock: v.NatGateway.NatGatewayDestinationCidrBlock,
-			NatGatewayId:         natGw.ID(),
-		})
+			DestinationCidrBlock: natGatewayDestinationCidrBlock,
+			NatGatewayId:         natGateways[azNatGateways[i]].ToStringOutput(),
+		}, v.legacyName("private-natgateway-"+az))
 		if err != nil {
 			return vpcCreateOutput, err
 		}
-		privateRouteTables = append(privateRouteTables, privateRouteTable.ID())
 	}
 	vpcCreateOutput.NatGatewaysIds = natGateways
 	vpcCreateOutput.PrivateRouteTableIds = privateRouteTables
 
+	err = v.createPrefixListRoutes(ctx, "private", v.PrivateSubnet.PrefixListRoutes, privateRouteTables)
+	if err != nil {
+		return vpcCreateOutput, err
+	}
+
 	// Create private Subnets
 	var privateSubnets pulumi.StringArray
+	var privateSubnetArns pulumi.StringArray
 	for index, subnet := range v.PrivateSubnet.Cidrs {
 		subnet, err := ec2.NewSubnet(ctx, v.Name+"-private-"+v.Azs[index], &ec2.SubnetArgs{
 			VpcId:                                   vpc.ID(),
@@ -213,35 +295,102 @@ func (v *Vpc) CreateVpc(ctx *pulumi.Context) (*VpcCreateOutput, error) {
 			Tags:                                    mergeTags(pulumi.StringMap{"Name": pulumi.String(v.Name + "-private-" + v.Azs[index])}, pulumi.StringMap{"kubernetes.io/role/internal-elb": pulumi.String("1")}, v.PrivateSubnet.Tags, v.Tags),
 		})
 		if err != nil {
-			if err != nil {
-				return vpcCreateOutput, err
-			}
-		}
-		if natGatewayCount > 1 {
-			_, err = ec2.NewRouteTableAssociation(ctx, v.Name+"-private-"+v.Azs[index], &ec2.RouteTableAssociationArgs{
-				SubnetId:     subnet.ID(),
-				RouteTableId: privateRouteTables[index],
-			})
-			if err != nil {
-				if err != nil {
-					return vpcCreateOutput, err
-				}
-			}
-		} else {
-			_, err = ec2.NewRouteTableAssociation(ctx, v.Name+"-private-"+v.Azs[index], &ec2.RouteTableAssociationArgs{
-				SubnetId:     subnet.ID(),
-				RouteTableId: privateRouteTables[0],
-			})
-			if err != nil {
-				if err != nil {
-					return vpcCreateOutput, err
-				}
-			}
+			return vpcCreateOutput, err
+		}
+		_, err = ec2.NewRouteTableAssociation(ctx, v.Name+"-private-"+v.Azs[index], &ec2.RouteTableAssociationArgs{
+			SubnetId:     subnet.ID(),
+			RouteTableId: privateRouteTables[index],
+		})
+		if err != nil {
+			return vpcCreateOutput, err
+		}
+		reserved, err := v.createCidrReservations(ctx, "private", v.PrivateSubnet, index, subnet.ID())
+		if err != nil {
+			return vpcCreateOutput, err
+		}
+		for name, cidr := range reserved {
+			vpcCreateOutput.CidrReservations[name] = cidr
 		}
 		privateSubnets = append(privateSubnets, subnet.ID())
+		privateSubnetArns = append(privateSubnetArns, subnet.Arn)
 	}
 	vpcCreateOutput.PrivateRouteTableIds = privateRouteTables
 	vpcCreateOutput.PrivateSubnetsIds = privateSubnets
 
+	// Create ElastiCache and Redshift subnets
+	err = v.createDataTiers(ctx, vpc.ID(), publicRouteTable.ID(), privateRouteTables, vpcCreateOutput)
+	if err != nil {
+		return vpcCreateOutput, err
+	}
+
+	// Associate with a Direct Connect gateway
+	if v.DirectConnect.DxGatewayId != nil {
+		err = v.createDirectConnect(ctx, vpc.ID(), vpcCreateOutput)
+		if err != nil {
+			return vpcCreateOutput, err
+		}
+	}
+
+	// Create private NatGateways
+	err = v.createPrivateNatGateways(ctx, vpc.ID(), publicRouteTable.ID(), privateRouteTables, vpcCreateOutput)
+	if err != nil {
+		return vpcCreateOutput, err
+	}
+
+	// Create Local Zone and Wavelength subnets
+	err = v.createEdgeSubnets(ctx, vpc, publicRouteTable.ID(), privateRouteTables, vpcCreateOutput)
+	if err != nil {
+		return vpcCreateOutput, err
+	}
+
+	// Share subnets with RAM
+	ramShares := []struct {
+		tier       string
+		share      RamShare
+		subnetArns pulumi.StringArray
+	}{
+		{"public", v.PublicSubnet.RamShare, publicSubnetArns},
+		{"private", v.PrivateSubnet.RamShare, privateSubnetArns},
+	}
+	for _, ramShare := range ramShares {
+		if len(ramShare.share.Principals) == 0 {
+			continue
+		}
+		shareArn, err := v.shareResources(ctx, ramShare.tier, ramShare.share, ramShare.subnetArns, v.Azs)
+		if err != nil {
+			return vpcCreateOutput, err
+		}
+		vpcCreateOutput.RamShareArns[ramShare.tier] = shareArn
+	}
+
+	tierSubnets := map[string]pulumi.StringArray{
+		"public":      publicSubnets,
+		"private":     privateSubnets,
+		"elasticache": vpcCreateOutput.ElasticacheSubnetIds,
+		"redshift":    vpcCreateOutput.RedshiftSubnetIds,
+	}
+
+	// Route53 Resolver endpoints and forwarding rules
+	err = v.createResolver(ctx, vpc.ID(), tierSubnets, vpcCreateOutput)
+	if err != nil {
+		return vpcCreateOutput, err
+	}
+
+	// Route53 Resolver DNS Firewall
+	err = v.createDnsFirewall(ctx, vpc.ID(), vpcCreateOutput)
+	if err != nil {
+		return vpcCreateOutput, err
+	}
+
+	// Network Insights reachability checks
+	err = v.createReachabilityPaths(ctx, igw, tierSubnets, vpcCreateOutput)
+	if err != nil {
+		return vpcCreateOutput, err
+	}
+
+	if len(vpcCreateOutput.CidrReservations) > 0 {
+		ctx.Export(v.Name+"-cidr-reservations", vpcCreateOutput.CidrReservations)
+	}
+
 	return vpcCreateOutput, nil
 }
@@ -0,0 +1,710 @@
+package vpc
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type mocks struct {
+	sync.Mutex
+	urns      map[string]int
+	resources map[string]resource.PropertyMap
+}
+
+func newMocks() *mocks {
+	return &mocks{urns: map[string]int{}, resources: map[string]resource.PropertyMap{}}
+}
+
+func (m *mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	m.Lock()
+	m.urns[args.TypeToken+"::"+args.Name]++
+	m.resources[args.TypeToken+"::"+args.Name] = args.Inputs
+	m.Unlock()
+	outputs := args.Inputs.Copy()
+	if args.TypeToken == "aws:ec2/vpc:Vpc" && args.Inputs["assignGeneratedIpv6CidrBlock"].IsBool() && args.Inputs["assignGeneratedIpv6CidrBlock"].BoolValue() {
+		outputs["ipv6CidrBlock"] = resource.NewStringProperty("2a05:d014:1234:5600::/56")
+	}
+	if args.TypeToken == "aws:ec2/networkInsightsAnalysis:NetworkInsightsAnalysis" {
+		outputs["pathFound"] = resource.NewBoolProperty(!strings.Contains(args.Name, "blocked"))
+	}
+	return args.Name + "_id", outputs, nil
+}
+
+// inputs returns the inputs of a resource, or nil if it was not registered.
+func (m *mocks) inputs(typeToken, name string) resource.PropertyMap {
+	m.Lock()
+	defer m.Unlock()
+	return m.resources[typeToken+"::"+name]
+}
+
+func (m *mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	if args.Token == "aws:index/getAvailabilityZone:getAvailabilityZone" {
+		// Local Zones of eu-central-1a and of an AZ the VPC doesn't use
+		name := args.Args["name"].StringValue()
+		parents := map[string]string{"eu-central-1-ham-1a": "eu-central-1a", "eu-central-1-waw-1a": "eu-central-1c"}
+		return resource.NewPropertyMapFromMap(map[string]interface{}{
+			"name":               name,
+			"parentZoneName":     parents[name],
+			"networkBorderGroup": name,
+			"zoneType":           "local-zone",
+			"optInStatus":        "opted-in",
+		}), nil
+	}
+	return args.Args, nil
+}
+
+func testVpc(name, cidr string, public, private []string) *Vpc {
+	return &Vpc{
+		Name:          name,
+		Cidr:          pulumi.String(cidr),
+		Azs:           []string{"eu-central-1a", "eu-central-1b"},
+		PublicSubnet:  Subnet{Cidrs: public},
+		PrivateSubnet: Subnet{Cidrs: private},
+		DhcpOption: DhcpOption{
+			Create:     true,
+			DomainName: "corp.internal",
+		},
+		NatGateway: NatGateway{
+			NatGatewayDestinationCidrBlock: pulumi.String("0.0.0.0/0"),
+		},
+	}
+}
+
+func TestCreateVpcSideBySide(t *testing.T) {
+	m := newMocks()
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		hub := testVpc("hub", "10.0.0.0/16", []string{"10.0.1.0/24", "10.0.2.0/24"}, []string{"10.0.101.0/24", "10.0.102.0/24"})
+		if _, err := hub.CreateVpc(ctx); err != nil {
+			return err
+		}
+		egress := testVpc("egress", "10.1.0.0/16", []string{"10.1.1.0/24", "10.1.2.0/24"}, []string{"10.1.101.0/24", "10.1.102.0/24"})
+		_, err := egress.CreateVpc(ctx)
+		return err
+	}, pulumi.WithMocks("project", "stack", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for urn, count := range m.urns {
+		if count > 1 {
+			t.Errorf("%s registered %d times", urn, count)
+		}
+	}
+}
+
+func TestNatGatewayTopology(t *testing.T) {
+	azs := []string{"eu-central-1a", "eu-central-1b", "eu-central-1c"}
+	tests := []struct {
+		name       string
+		natGateway NatGateway
+		count      int
+		mapping    []int
+	}{
+		{"default", NatGateway{}, 3, []int{0, 1, 2}},
+		{"legacy single", NatGateway{SingleNatGateway: true}, 1, []int{0, 0, 0}},
+		{"none", NatGateway{Mode: "none"}, 0, []int{-1, -1, -1}},
+		{"single", NatGateway{Mode: "single"}, 1, []int{0, 0, 0}},
+		{"one per az", NatGateway{Mode: "one-per-az"}, 3, []int{0, 1, 2}},
+		{"shared", NatGateway{Mode: "shared", Count: 2}, 2, []int{0, 1, 0}},
+		{"shared with mapping", NatGateway{Mode: "shared", Count: 2, AzMapping: map[string]int{"eu-central-1c": 1}}, 2, []int{0, 1, 1}},
+	}
+	for _, tt := range tests {
+		v := &Vpc{Azs: azs, NatGateway: tt.natGateway}
+		count, mapping, err := v.natGatewayTopology()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if count != tt.count {
+			t.Errorf("%s: got %d nat gateways, want %d", tt.name, count, tt.count)
+		}
+		for index := range mapping {
+			if mapping[index] != tt.mapping[index] {
+				t.Errorf("%s: got mapping %v, want %v", tt.name, mapping, tt.mapping)
+				break
+			}
+		}
+	}
+
+	for name, natGateway := range map[string]NatGateway{
+		"shared without count":  {Mode: "shared"},
+		"shared above az count": {Mode: "shared", Count: 4},
+		"mapping above count":   {Mode: "shared", Count: 2, AzMapping: map[string]int{"eu-central-1c": 2}},
+		"negative mapping":      {Mode: "shared", Count: 2, AzMapping: map[string]int{"eu-central-1a": -1}},
+		"unknown mode":          {Mode: "per-subnet"},
+	} {
+		v := &Vpc{Azs: azs, NatGateway: natGateway}
+		if _, _, err := v.natGatewayTopology(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestDataSubnetsNatPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		natGateway NatGateway
+		ok         bool
+	}{
+		{"one per az", NatGateway{}, true},
+		{"single", NatGateway{Mode: "single"}, true},
+		{"none", NatGateway{Mode: "none"}, false},
+	}
+	for _, tt := range tests {
+		m := newMocks()
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			v := testVpc("hub", "10.0.0.0/16", []string{"10.0.1.0/24", "10.0.2.0/24"}, []string{"10.0.101.0/24", "10.0.102.0/24"})
+			v.NatGateway = tt.natGateway
+			v.Elasticache = Subnet{Cidrs: []string{"10.0.201.0/24", "10.0.202.0/24"}, RouteTablePolicy: "nat"}
+			_, err := v.CreateVpc(ctx)
+			return err
+		}, pulumi.WithMocks("project", "stack", m))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.name, err)
+			continue
+		}
+		if tt.ok {
+			association := m.inputs("aws:ec2/routeTableAssociation:RouteTableAssociation", "hub-elasticache-eu-central-1b")
+			if association["routeTableId"].StringValue() != "hub-private-eu-central-1b_id" {
+				t.Errorf("%s: got route table %v", tt.name, association["routeTableId"])
+			}
+		}
+	}
+}
+
+func TestPrivateNatRouteTarget(t *testing.T) {
+	directConnect := DirectConnect{DxGatewayId: pulumi.String("dx-1")}
+	tests := []struct {
+		name          string
+		directConnect DirectConnect
+		privateNat    PrivateNatGateway
+		target        string
+	}{
+		{"transit gateway", DirectConnect{}, PrivateNatGateway{TransitGatewayId: pulumi.String("tgw-1")}, "transitGatewayId"},
+		{"vpn gateway", DirectConnect{}, PrivateNatGateway{VpnGatewayId: pulumi.String("vgw-1")}, "gatewayId"},
+		{"direct connect", directConnect, PrivateNatGateway{UseDirectConnectGateway: true}, "gatewayId"},
+		{"no target", directConnect, PrivateNatGateway{}, ""},
+		{"two targets", DirectConnect{}, PrivateNatGateway{TransitGatewayId: pulumi.String("tgw-1"), VpnGatewayId: pulumi.String("vgw-1")}, ""},
+		{"direct connect without vgw", DirectConnect{}, PrivateNatGateway{UseDirectConnectGateway: true}, ""},
+		{"direct connect through a transit gateway", DirectConnect{DxGatewayId: pulumi.String("dx-1"), TransitGatewayId: pulumi.String("tgw-1")}, PrivateNatGateway{UseDirectConnectGateway: true}, ""},
+	}
+	for _, tt := range tests {
+		m := newMocks()
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			v := testVpc("hub", "10.0.0.0/16", []string{"10.0.1.0/24", "10.0.2.0/24"}, []string{"10.0.101.0/24", "10.0.102.0/24"})
+			v.DirectConnect = tt.directConnect
+			tt.privateNat.Name = "partner"
+			tt.privateNat.Cidrs = []string{"100.64.0.0/28", "100.64.0.16/28"}
+			tt.privateNat.DestinationCidrBlocks = []string{"192.168.0.0/16"}
+			v.NatGateway.PrivateNatGateways = []PrivateNatGateway{tt.privateNat}
+			_, err := v.CreateVpc(ctx)
+			return err
+		}, pulumi.WithMocks("project", "stack", m))
+		if tt.target == "" {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		route := m.inputs("aws:ec2/route:Route", "hub-partner-0")
+		if route == nil || !route.HasValue(resource.PropertyKey(tt.target)) {
+			t.Errorf("%s: got route %v", tt.name, route)
+		}
+		if tt.privateNat.UseDirectConnectGateway && route["gatewayId"].StringValue() != "hub_id" {
+			t.Errorf("%s: got gateway %v, want the DirectConnect virtual private gateway", tt.name, route["gatewayId"])
+		}
+	}
+}
+
+func TestPrivateNatSecondaryIps(t *testing.T) {
+	m := newMocks()
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		v := testVpc("hub", "10.0.0.0/16", []string{"10.0.1.0/24", "10.0.2.0/24"}, []string{"10.0.101.0/24", "10.0.102.0/24"})
+		v.NatGateway.PrivateNatGateways = []PrivateNatGateway{{
+			Name:                "partner",
+			Cidrs:               []string{"100.64.0.0/28", "100.64.0.16/28"},
+			PrivateIps:          []string{"100.64.0.4", "100.64.0.20"},
+			SecondaryPrivateIps: [][]string{{"100.64.0.5", "100.64.0.6"}},
+			RouteTiers:          []string{"private"},
+			TransitGatewayId:    pulumi.String("tgw-1"),
+		}}
+		_, err := v.CreateVpc(ctx)
+		return err
+	}, pulumi.WithMocks("project", "stack", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := m.inputs("aws:ec2/natGateway:NatGateway", "hub-partner-eu-central-1a")
+	if first["connectivityType"].StringValue() != "private" || first["privateIp"].StringValue() != "100.64.0.4" || len(first["secondaryPrivateIpAddresses"].ArrayValue()) != 2 {
+		t.Errorf("got nat gateway %v", first)
+	}
+	second := m.inputs("aws:ec2/natGateway:NatGateway", "hub-partner-eu-central-1b")
+	if second.HasValue("secondaryPrivateIpAddresses") {
+		t.Errorf("got nat gateway %v", second)
+	}
+
+	err = pulumi.RunErr(func(ctx *pulumi.Context) error {
+		v := testVpc("hub", "10.0.0.0/16", []string{"10.0.1.0/24", "10.0.2.0/24"}, []string{"10.0.101.0/24", "10.0.102.0/24"})
+		v.NatGateway.PrivateNatGateways = []PrivateNatGateway{{
+			Name:                    "partner",
+			Cidrs:                   []string{"100.64.0.0/28"},
+			SecondaryPrivateIps:     [][]string{{"100.64.0.5"}},
+			SecondaryPrivateIpCount: 2,
+			TransitGatewayId:        pulumi.String("tgw-1"),
+		}}
+		_, err := v.CreateVpc(ctx)
+		return err
+	}, pulumi.WithMocks("project", "stack", newMocks()))
+	if err == nil {
+		t.Error("secondary IPs together with a count should fail")
+	}
+}
+
+func TestEdgeIpv6Netnums(t *testing.T) {
+	tests := []struct {
+		name    string
+		edges   []EdgeSubnet
+		netnums [][]int
+	}{
+		{"in order", []EdgeSubnet{{Name: "a", Cidrs: []string{"a", "b"}}, {Name: "b", Cidrs: []string{"c"}}}, [][]int{{0, 1}, {2}}},
+		{"explicit reserved first", []EdgeSubnet{{Name: "a", Cidrs: []string{"a", "b"}}, {Name: "b", Cidrs: []string{"c"}, Ipv6Netnums: []int{1}}}, [][]int{{0, 2}, {1}}},
+		{"partly explicit", []EdgeSubnet{{Name: "a", Cidrs: []string{"a", "b"}, Ipv6Netnums: []int{0}}}, [][]int{{0, 1}}},
+	}
+	for _, tt := range tests {
+		v := &Vpc{EdgeSubnets: tt.edges}
+		netnums, err := v.edgeIpv6Netnums()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for edgeIndex := range tt.netnums {
+			for index := range tt.netnums[edgeIndex] {
+				if netnums[edgeIndex][index] != tt.netnums[edgeIndex][index] {
+					t.Errorf("%s: got %v, want %v", tt.name, netnums, tt.netnums)
+				}
+			}
+		}
+	}
+
+	for name, edges := range map[string][]EdgeSubnet{
+		"duplicate": {{Name: "a", Cidrs: []string{"a"}, Ipv6Netnums: []int{3}}, {Name: "b", Cidrs: []string{"b"}, Ipv6Netnums: []int{3}}},
+		"too large": {{Name: "a", Cidrs: []string{"a"}, Ipv6Netnums: []int{256}}},
+	} {
+		v := &Vpc{EdgeSubnets: edges}
+		if _, err := v.edgeIpv6Netnums(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestEdgeSubnetRouteTables(t *testing.T) {
+	tests := []struct {
+		name string
+		zone string
+		ok   bool
+	}{
+		{"parent in the VPC", "eu-central-1-ham-1a", true},
+		{"parent outside the VPC", "eu-central-1-waw-1a", false},
+	}
+	for _, tt := range tests {
+		m := newMocks()
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			v := testVpc("hub", "10.0.0.0/16", []string{"10.0.1.0/24", "10.0.2.0/24"}, []string{"10.0.101.0/24", "10.0.102.0/24"})
+			v.EnableIpv6 = true
+			v.EdgeSubnets = []EdgeSubnet{{Name: "edge", ZoneType: "local-zone", Zones: []string{tt.zone}, Cidrs: []string{"10.0.200.0/24"}}}
+			_, err := v.CreateVpc(ctx)
+			return err
+		}, pulumi.WithMocks("project", "stack", m))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.name, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		association := m.inputs("aws:ec2/routeTableAssociation:RouteTableAssociation", "hub-edge-"+tt.zone)
+		if association["routeTableId"].StringValue() != "hub-private-eu-central-1a_id" {
+			t.Errorf("%s: got route table %v", tt.name, association["routeTableId"])
+		}
+		if !m.inputs("aws:ec2/vpc:Vpc", "hub")["assignGeneratedIpv6CidrBlock"].BoolValue() {
+			t.Errorf("%s: the VPC has no IPv6 block", tt.name)
+		}
+	}
+}
+
+func TestReachabilityPaths(t *testing.T) {
+	run := func(paths []ReachabilityPath) (*mocks, error) {
+		m := newMocks()
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			v := testVpc("hub", "10.0.0.0/16", []string{"10.0.1.0/24", "10.0.2.0/24"}, []string{"10.0.101.0/24", "10.0.102.0/24"})
+			v.ReachabilityPaths = paths
+			_, err := v.CreateVpc(ctx)
+			return err
+		}, pulumi.WithMocks("project", "stack", m))
+		return m, err
+	}
+	trigger := func(m *mocks, name string) string {
+		return m.inputs("aws:ec2/networkInsightsAnalysis:NetworkInsightsAnalysis", name)["tags"].ObjectValue()["Trigger"].StringValue()
+	}
+
+	m, err := run([]ReachabilityPath{{Name: "egress", SourceTier: "private"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, az := range []string{"eu-central-1a", "eu-central-1b"} {
+		if m.inputs("aws:ec2/networkInsightsPath:NetworkInsightsPath", "hub-egress-"+az) == nil {
+			t.Errorf("no path from %s", az)
+		}
+	}
+	before := trigger(m, "hub-egress-eu-central-1a")
+
+	m, err = run([]ReachabilityPath{{Name: "egress", SourceTier: "private", Triggers: pulumi.StringArray{pulumi.String("rtb-1")}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trigger(m, "hub-egress-eu-central-1a") == before {
+		t.Error("the trigger didn't change with Triggers")
+	}
+
+	if _, err := run([]ReachabilityPath{{Name: "blocked", SourceTier: "private"}}); err != nil {
+		t.Errorf("a path not found failed without FailOnPathNotFound: %v", err)
+	}
+	if _, err := run([]ReachabilityPath{{Name: "blocked", SourceTier: "private", FailOnPathNotFound: true}}); err == nil || !strings.Contains(err.Error(), "no path found") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestCreatePrefixListMaxEntries(t *testing.T) {
+	tests := []struct {
+		name       string
+		prefixList PrefixList
+		maxEntries float64
+	}{
+		{"empty", PrefixList{Name: "empty"}, 1},
+		{"entries", PrefixList{Name: "offices", Entries: []PrefixListEntry{{Cidr: "10.10.0.0/16"}, {Cidr: "10.20.0.0/16"}}}, 2},
+		{"explicit", PrefixList{Name: "partners", MaxEntries: pulumi.Int(20)}, 20},
+	}
+	for _, tt := range tests {
+		m := newMocks()
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			_, err := tt.prefixList.CreatePrefixList(ctx)
+			return err
+		}, pulumi.WithMocks("project", "stack", m))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := m.inputs("aws:ec2/managedPrefixList:ManagedPrefixList", tt.prefixList.Name)["maxEntries"].NumberValue(); got != tt.maxEntries {
+			t.Errorf("%s: got %v max entries, want %v", tt.name, got, tt.maxEntries)
+		}
+	}
+}
+
+func TestDataTierRoutesAndShares(t *testing.T) {
+	run := func(configure func(v *Vpc)) (*mocks, error) {
+		m := newMocks()
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			v := testVpc("hub", "10.0.0.0/16", []string{"10.0.1.0/24", "10.0.2.0/24"}, []string{"10.0.101.0/24", "10.0.102.0/24"})
+			configure(v)
+			_, err := v.CreateVpc(ctx)
+			return err
+		}, pulumi.WithMocks("project", "stack", m))
+		return m, err
+	}
+	routes := []PrefixListRoute{{Name: "offices", PrefixListId: pulumi.String("pl-1"), TransitGatewayId: pulumi.String("tgw-1")}}
+	share := RamShare{Principals: []string{"111111111111"}}
+
+	m, err := run(func(v *Vpc) {
+		v.Elasticache = Subnet{Cidrs: []string{"10.0.201.0/24", "10.0.202.0/24"}, PrefixListRoutes: routes, RamShare: share}
+		v.Redshift = Subnet{Cidrs: []string{"10.0.211.0/24", "10.0.212.0/24"}, RamShare: share}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	route := m.inputs("aws:ec2/route:Route", "hub-elasticache-offices-0")
+	if route == nil || route["routeTableId"].StringValue() != "hub-elasticache_id" {
+		t.Errorf("got prefix list route %v", route)
+	}
+	for _, tier := range []string{"elasticache", "redshift"} {
+		if m.inputs("aws:ram/resourceShare:ResourceShare", "hub-"+tier) == nil {
+			t.Errorf("%s subnets are not shared", tier)
+		}
+		for _, az := range []string{"eu-central-1a", "eu-central-1b"} {
+			if m.inputs("aws:ram/resourceAssociation:ResourceAssociation", "hub-"+tier+"-"+az) == nil {
+				t.Errorf("%s subnet in %s is not shared", tier, az)
+			}
+		}
+	}
+
+	for name, configure := range map[string]func(v *Vpc){
+		"shared route table": func(v *Vpc) {
+			v.Redshift = Subnet{Cidrs: []string{"10.0.211.0/24", "10.0.212.0/24"}, RouteTablePolicy: "nat", PrefixListRoutes: routes}
+		},
+		"database routes": func(v *Vpc) { v.Database = Subnet{PrefixListRoutes: routes} },
+		"database share":  func(v *Vpc) { v.Database = Subnet{RamShare: share} },
+	} {
+		if _, err := run(configure); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestValidateDnsFirewall(t *testing.T) {
+	rules := func(priorities ...int) []DnsFirewallRule {
+		var rules []DnsFirewallRule
+		for index, priority := range priorities {
+			rules = append(rules, DnsFirewallRule{Name: "rule-" + strconv.Itoa(index), Priority: priority})
+		}
+		return rules
+	}
+	tests := []struct {
+		name       string
+		ruleGroups []DnsFirewallRuleGroup
+		ok         bool
+	}{
+		{"valid", []DnsFirewallRuleGroup{{Name: "a", Priority: 101, Rules: rules(1, 2)}, {Name: "b", Priority: 9899, Rules: rules(1)}}, true},
+		{"group priority too low", []DnsFirewallRuleGroup{{Name: "a", Priority: 100}}, false},
+		{"group priority too high", []DnsFirewallRuleGroup{{Name: "a", Priority: 9900}}, false},
+		{"group priority unset", []DnsFirewallRuleGroup{{Name: "a"}}, false},
+		{"duplicate group priority", []DnsFirewallRuleGroup{{Name: "a", Priority: 200}, {Name: "b", Priority: 200}}, false},
+		{"duplicate rule priority", []DnsFirewallRuleGroup{{Name: "a", Priority: 200, Rules: rules(1, 1)}}, false},
+		{"rule priority unset", []DnsFirewallRuleGroup{{Name: "a", Priority: 200, Rules: rules(0)}}, false},
+	}
+	for _, tt := range tests {
+		v := &Vpc{DnsFirewall: DnsFirewall{RuleGroups: tt.ruleGroups}}
+		if err := v.validateDnsFirewall(); (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+}
+
+func TestCreateResolver(t *testing.T) {
+	run := func(resolver Resolver) (*mocks, error) {
+		m := newMocks()
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			v := testVpc("hub", "10.0.0.0/16", []string{"10.0.1.0/24", "10.0.2.0/24"}, []string{"10.0.101.0/24", "10.0.102.0/24"})
+			v.Resolver = resolver
+			_, err := v.CreateVpc(ctx)
+			return err
+		}, pulumi.WithMocks("project", "stack", m))
+		return m, err
+	}
+
+	m, err := run(Resolver{
+		AllowedCidrs: []string{"192.168.0.0/16"},
+		Inbound:      ResolverEndpoint{Create: true, IpAddresses: []string{"10.0.101.53"}},
+		Outbound:     ResolverEndpoint{Create: true, SubnetTier: "public"},
+		RamShare:     RamShare{Principals: []string{"111111111111"}},
+		Rules:        []ResolverRule{{Name: "corp", DomainName: "corp.example.com", TargetIps: []ResolverTargetIp{{Ip: "192.168.0.2"}, {Ip: "192.168.0.3", Port: 5353}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inbound := m.inputs("aws:route53/resolverEndpoint:ResolverEndpoint", "hub-resolver-inbound")["ipAddresses"].ArrayValue()
+	if len(inbound) != 2 || inbound[0].ObjectValue()["subnetId"].StringValue() != "hub-private-eu-central-1a_id" || inbound[0].ObjectValue()["ip"].StringValue() != "10.0.101.53" || inbound[1].ObjectValue().HasValue("ip") {
+		t.Errorf("got inbound ip addresses %v", inbound)
+	}
+	outbound := m.inputs("aws:route53/resolverEndpoint:ResolverEndpoint", "hub-resolver-outbound")["ipAddresses"].ArrayValue()
+	if len(outbound) != 2 || outbound[1].ObjectValue()["subnetId"].StringValue() != "hub-public-eu-central-1b_id" {
+		t.Errorf("got outbound ip addresses %v", outbound)
+	}
+	ingress := m.inputs("aws:ec2/securityGroupRule:SecurityGroupRule", "hub-resolver-ingress-udp")["cidrBlocks"].ArrayValue()
+	if len(ingress) != 2 || ingress[1].StringValue() != "192.168.0.0/16" {
+		t.Errorf("got ingress cidrs %v", ingress)
+	}
+	rule := m.inputs("aws:route53/resolverRule:ResolverRule", "hub-corp")
+	if rule["resolverEndpointId"].StringValue() != "hub-resolver-outbound_id" || len(rule["targetIps"].ArrayValue()) != 2 {
+		t.Errorf("got resolver rule %v", rule)
+	}
+	if m.inputs("aws:route53/resolverRuleAssociation:ResolverRuleAssociation", "hub-corp") == nil {
+		t.Error("the resolver rule is not associated with the VPC")
+	}
+	if m.inputs("aws:ram/resourceAssociation:ResourceAssociation", "hub-resolver-rules-corp") == nil {
+		t.Error("the resolver rule is not shared")
+	}
+
+	for name, resolver := range map[string]Resolver{
+		"rules without outbound endpoint": {Inbound: ResolverEndpoint{Create: true}, Rules: []ResolverRule{{Name: "corp"}}},
+		"unknown subnet tier":             {Inbound: ResolverEndpoint{Create: true, SubnetTier: "database"}},
+	} {
+		if _, err := run(resolver); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestReadDomainFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	if err := os.WriteFile(path, []byte("# blocked\nmalware.example.com\n\n  *.phishing.example.com  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	domains, err := readDomainFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(domains, ",") != "malware.example.com,*.phishing.example.com" {
+		t.Errorf("got %v", domains)
+	}
+	if _, err := readDomainFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestCreateDnsFirewall(t *testing.T) {
+	m := newMocks()
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		v := testVpc("hub", "10.0.0.0/16", []string{"10.0.1.0/24", "10.0.2.0/24"}, []string{"10.0.101.0/24", "10.0.102.0/24"})
+		v.DnsFirewall = DnsFirewall{
+			DomainLists: []DnsFirewallDomainList{{Name: "blocked", Domains: []string{"malware.example.com"}}},
+			RuleGroups: []DnsFirewallRuleGroup{{
+				Name:     "egress",
+				Priority: 200,
+				Rules: []DnsFirewallRule{
+					{Name: "block", Action: "BLOCK", DomainList: "blocked", Priority: 1},
+					{Name: "override", Action: "BLOCK", BlockResponse: "OVERRIDE", BlockOverrideDomain: "sinkhole.example.com", BlockOverrideTtl: 60, DomainList: "blocked", Priority: 2},
+				},
+			}},
+			QueryLog: DnsQueryLog{Create: true},
+		}
+		_, err := v.CreateVpc(ctx)
+		return err
+	}, pulumi.WithMocks("project", "stack", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := m.inputs("aws:route53/resolverFirewallRule:ResolverFirewallRule", "hub-egress-block")
+	if block["blockResponse"].StringValue() != "NODATA" || block["firewallDomainListId"].StringValue() != "hub-blocked_id" {
+		t.Errorf("got rule %v", block)
+	}
+	override := m.inputs("aws:route53/resolverFirewallRule:ResolverFirewallRule", "hub-egress-override")
+	if override["blockOverrideDnsType"].StringValue() != "CNAME" || override["blockOverrideDomain"].StringValue() != "sinkhole.example.com" {
+		t.Errorf("got rule %v", override)
+	}
+	association := m.inputs("aws:route53/resolverFirewallRuleGroupAssociation:ResolverFirewallRuleGroupAssociation", "hub-egress")
+	if association["priority"].NumberValue() != 200 || association["vpcId"].StringValue() != "hub_id" {
+		t.Errorf("got association %v", association)
+	}
+	if m.inputs("aws:route53/resolverFirewallConfig:ResolverFirewallConfig", "hub")["firewallFailOpen"].StringValue() != "DISABLED" {
+		t.Error("the firewall does not fail closed")
+	}
+	queryLog := m.inputs("aws:route53/resolverQueryLogConfig:ResolverQueryLogConfig", "hub")
+	if queryLog == nil || m.inputs("aws:cloudwatch/logGroup:LogGroup", "hub-dns-query-log") == nil {
+		t.Error("no query log to CloudWatch Logs")
+	}
+}
+
+func TestCreateIngressInspection(t *testing.T) {
+	run := func(cidrs []string) (*mocks, error) {
+		m := newMocks()
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			v := testVpc("hub", "10.0.0.0/16", []string{"10.0.1.0/24", "10.0.2.0/24"}, []string{"10.0.101.0/24", "10.0.102.0/24"})
+			v.IngressInspection = IngressInspection{
+				Cidrs:               cidrs,
+				EndpointServiceName: pulumi.String("com.amazonaws.vpce.eu-central-1.vpce-svc-1"),
+			}
+			_, err := v.CreateVpc(ctx)
+			return err
+		}, pulumi.WithMocks("project", "stack", m))
+		return m, err
+	}
+
+	m, err := run([]string{"10.0.251.0/28", "10.0.252.0/28"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.inputs("aws:ec2/routeTableAssociation:RouteTableAssociation", "hub-igw-edge")["gatewayId"].StringValue() != "hub_id" {
+		t.Error("the edge route table is not associated with the internet gateway")
+	}
+	for _, az := range []string{"eu-central-1a", "eu-central-1b"} {
+		endpointId := "hub-gwlbe-" + az + "_id"
+		if m.inputs("aws:ec2/route:Route", "hub-igw-edge-"+az)["vpcEndpointId"].StringValue() != endpointId {
+			t.Errorf("inbound traffic of %s does not go through its endpoint", az)
+		}
+		if m.inputs("aws:ec2/route:Route", "hub-public-gwlbe-"+az)["vpcEndpointId"].StringValue() != endpointId {
+			t.Errorf("outbound traffic of %s does not go through its endpoint", az)
+		}
+		association := m.inputs("aws:ec2/routeTableAssociation:RouteTableAssociation", "hub-public-"+az)
+		if association["routeTableId"].StringValue() != "hub-public-"+az+"_id" {
+			t.Errorf("public subnet of %s is associated with %v", az, association["routeTableId"])
+		}
+		if m.inputs("aws:ec2/routeTableAssociation:RouteTableAssociation", "hub-appliance-"+az)["routeTableId"].StringValue() != "hub-appliance_id" {
+			t.Errorf("appliance subnet of %s is not associated with the appliance route table", az)
+		}
+	}
+
+	if _, err := run([]string{"10.0.251.0/28"}); err == nil {
+		t.Error("expected an error for fewer appliance subnets than public subnets")
+	}
+}
+
+func TestSubnetCidr(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		newBits int
+		netnum  int
+		want    string
+	}{
+		{"10.0.101.0/24", 4, 0, "10.0.101.0/28"},
+		{"10.0.101.0/24", 4, 15, "10.0.101.240/28"},
+		{"10.0.101.0/24", 8, 3, "10.0.101.3/32"},
+		{"2a05:d014:1234:5600::/56", 8, 2, "2a05:d014:1234:5602::/64"},
+	}
+	for _, tt := range tests {
+		got, err := subnetCidr(tt.cidr, tt.newBits, tt.netnum)
+		if err != nil {
+			t.Errorf("%s %d %d: %v", tt.cidr, tt.newBits, tt.netnum, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s %d %d: got %s, want %s", tt.cidr, tt.newBits, tt.netnum, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		cidr    string
+		newBits int
+		netnum  int
+	}{
+		{"10.0.101.0", 4, 0},
+		{"10.0.101.0/24", 0, 0},
+		{"10.0.101.0/24", 9, 0},
+		{"10.0.101.0/24", 4, 16},
+		{"10.0.101.0/24", 4, -1},
+	} {
+		if _, err := subnetCidr(tt.cidr, tt.newBits, tt.netnum); err == nil {
+			t.Errorf("%s %d %d: expected an error", tt.cidr, tt.newBits, tt.netnum)
+		}
+	}
+}
+
+func TestCreateCidrReservations(t *testing.T) {
+	m := newMocks()
+	var reservations pulumi.StringMap
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		v := testVpc("hub", "10.0.0.0/16", []string{"10.0.1.0/24", "10.0.2.0/24"}, []string{"10.0.101.0/24", "10.0.102.0/24"})
+		v.PrivateSubnet.CidrReservations = []CidrReservation{{NewBits: 4, Netnum: 15}, {NewBits: 4, Netnum: 14, ReservationType: pulumi.String("explicit")}}
+		output, err := v.CreateVpc(ctx)
+		reservations = output.CidrReservations
+		return err
+	}, pulumi.WithMocks("project", "stack", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reservations) != 4 {
+		t.Errorf("got %d reservations, want 4", len(reservations))
+	}
+	reservation := m.inputs("aws:ec2/subnetCidrReservation:SubnetCidrReservation", "hub-private-eu-central-1b-0")
+	if reservation["cidrBlock"].StringValue() != "10.0.102.240/28" || reservation["reservationType"].StringValue() != "prefix" || reservation["subnetId"].StringValue() != "hub-private-eu-central-1b_id" {
+		t.Errorf("got reservation %v", reservation)
+	}
+	if m.inputs("aws:ec2/subnetCidrReservation:SubnetCidrReservation", "hub-private-eu-central-1a-1")["reservationType"].StringValue() != "explicit" {
+		t.Error("the reservation type is not kept")
+	}
+}